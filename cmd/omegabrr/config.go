@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// runConfigCommand runs a config subcommand and returns the exit code.
+func runConfigCommand(w io.Writer, sub, configPath string, redacted bool) int {
+	switch sub {
+	case "validate":
+		return validateConfig(w, configPath)
+	case "show":
+		return showConfig(w, configPath, redacted)
+	case "migrate":
+		return migrateConfig(w, configPath)
+	case "schema":
+		return printSchema(w)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown config command: %q\n", sub)
+		return 1
+	}
+}
+
+// validateConfig prints every problem with the config file and returns 1 if there are any.
+func validateConfig(w io.Writer, configPath string) int {
+	if configPath == "" {
+		fmt.Fprintln(os.Stderr, "no config file found, provide one with --config")
+		return 1
+	}
+
+	_, err := domain.LoadConfig(configPath)
+	if err == nil {
+		fmt.Fprintf(w, "%s is valid\n", configPath)
+		return 0
+	}
+
+	printConfigErrors(w, configPath, err)
+
+	return 1
+}
+
+// printConfigErrors prints every problem found while loading the config file.
+func printConfigErrors(w io.Writer, configPath string, err error) {
+	var validationErrs domain.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		fmt.Fprintf(w, "%s: %v\n", configPath, err)
+		return
+	}
+
+	for _, e := range validationErrs {
+		// problems in included files name their file
+		if e.File == "" {
+			e.File = configPath
+		}
+		fmt.Fprintln(w, e)
+	}
+	if len(validationErrs) == 1 {
+		fmt.Fprintln(w, "found 1 problem")
+	} else {
+		fmt.Fprintf(w, "found %d problems\n", len(validationErrs))
+	}
+}
+
+// showConfig prints the config as it is loaded, after includes, environment
+// variables, defaults and secrets are applied.
+func showConfig(w io.Writer, configPath string, redacted bool) int {
+	if configPath == "" {
+		fmt.Fprintln(os.Stderr, "no config file found, provide one with --config")
+		return 1
+	}
+
+	cfg, err := domain.LoadConfig(configPath)
+	if err != nil {
+		printConfigErrors(os.Stderr, configPath, err)
+		return 1
+	}
+
+	if redacted {
+		cfg = cfg.Redacted()
+	}
+
+	out, err := yaml.Marshal(cfg.Map())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not print config: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(w, "# effective config loaded from %s\n", configPath)
+	if _, err := w.Write(out); err != nil {
+		fmt.Fprintf(os.Stderr, "could not print config: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// migrateConfig upgrades the config file and the files it includes to the current
+// version in place, keeping each original next to it with a .bak suffix.
+func migrateConfig(w io.Writer, configPath string) int {
+	if configPath == "" {
+		fmt.Fprintln(os.Stderr, "no config file found, provide one with --config")
+		return 1
+	}
+
+	if code := migrateFile(w, configPath); code != 0 {
+		return code
+	}
+
+	includes, err := domain.IncludedFiles(configPath)
+	if err != nil {
+		printConfigErrors(os.Stderr, configPath, err)
+		return 1
+	}
+
+	code := 0
+	for _, file := range includes {
+		if c := migrateFile(w, file); c != 0 {
+			code = c
+		}
+	}
+
+	return code
+}
+
+// migrateFile upgrades a single config or included file.
+func migrateFile(w io.Writer, path string) int {
+	info, err := os.Stat(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not read %s: %v\n", path, err)
+		return 1
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not read %s: %v\n", path, err)
+		return 1
+	}
+
+	migrated, from, warnings, err := domain.MigrateConfig(content)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return 1
+	}
+
+	switch {
+	case from > domain.ConfigVersion:
+		fmt.Fprintf(os.Stderr, "%s is at version %d, which is newer than this omegabrr supports (%d)\n", path, from, domain.ConfigVersion)
+		return 1
+	case from == domain.ConfigVersion:
+		fmt.Fprintf(w, "%s is already at version %d\n", path, from)
+		return 0
+	}
+
+	backup := path + ".bak"
+	if err := os.WriteFile(backup, content, info.Mode().Perm()); err != nil {
+		fmt.Fprintf(os.Stderr, "could not write backup: %v\n", err)
+		return 1
+	}
+
+	if err := os.WriteFile(path, migrated, info.Mode().Perm()); err != nil {
+		fmt.Fprintf(os.Stderr, "could not write %s: %v\n", path, err)
+		return 1
+	}
+
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "%s: %s\n", path, warning)
+	}
+	fmt.Fprintf(w, "migrated %s from version %d to %d, the original is kept as %s\n", path, from, domain.ConfigVersion, backup)
+
+	return 0
+}
+
+// printSchema prints the JSON Schema of config.yaml.
+func printSchema(w io.Writer) int {
+	schema, err := domain.SchemaJSON()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not generate schema: %v\n", err)
+		return 1
+	}
+
+	if _, err := w.Write(schema); err != nil {
+		fmt.Fprintf(os.Stderr, "could not write schema: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+	"github.com/autobrr/omegabrr/internal/history"
+
+	"github.com/rs/zerolog/log"
+)
+
+// openHistory opens the history store for the config, or returns nil if it can't.
+func openHistory(cfg *domain.Config) *history.Store {
+	if cfg.StateDir == "" {
+		return nil
+	}
+
+	store, err := history.NewStore(cfg.StateDir, cfg.History.Retention)
+	if err != nil {
+		log.Warn().Err(err).Msg("run history disabled")
+		return nil
+	}
+
+	return store
+}
+
+func printRuns(w io.Writer, runs []*domain.RunReport) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintln(tw, "ID\tTRIGGER\tSTARTED\tDURATION\tSOURCES\tFILTERS\tERRORS")
+	for _, run := range runs {
+		var updated int
+		for _, f := range run.Filters {
+			if f.Status == domain.FilterStatusUpdated {
+				updated++
+			}
+		}
+
+		trigger := runTrigger(run)
+		if run.DryRun {
+			trigger += " (dry-run)"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d/%d updated\t%d\n",
+			run.ID,
+			trigger,
+			run.StartedAt.Local().Format(time.DateTime),
+			run.Duration.Round(time.Millisecond),
+			len(run.Sources),
+			updated,
+			len(run.Filters),
+			len(run.Errors()),
+		)
+	}
+}
+
+// runTrigger names the trigger of a run, and the triggers merged into it.
+func runTrigger(run *domain.RunReport) string {
+	triggers := []string{string(run.Trigger)}
+	for _, trigger := range run.MergedTriggers {
+		triggers = append(triggers, string(trigger))
+	}
+
+	return strings.Join(triggers, "+")
+}
+
+func printRun(w io.Writer, run *domain.RunReport) {
+	fmt.Fprintf(w, "Run:      %s\n", run.ID)
+	fmt.Fprintf(w, "Trigger:  %s\n", runTrigger(run))
+	fmt.Fprintf(w, "Dry-run:  %v\n", run.DryRun)
+	fmt.Fprintf(w, "Started:  %s\n", run.StartedAt.Local().Format(time.DateTime))
+	fmt.Fprintf(w, "Duration: %s\n\n", run.Duration.Round(time.Millisecond))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tTYPE\tSTATUS\tITEMS\tTITLES\tERROR")
+	for _, src := range run.Sources {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\n", src.Name, src.Type, src.Status, src.ItemsFetched, src.TitlesGenerated, runError(src.Error))
+	}
+	tw.Flush()
+
+	for _, f := range run.Filters {
+		fmt.Fprintf(w, "\nFilter %d: %s, %d titles (sources: %s)\n", f.ID, f.Status, f.Titles, strings.Join(f.Sources, ", "))
+		if f.Error != nil {
+			fmt.Fprintf(w, "  error: %s\n", f.Error)
+		}
+		for _, t := range f.Added {
+			fmt.Fprintf(w, "  + %s\n", t)
+		}
+		for _, t := range f.Removed {
+			fmt.Fprintf(w, "  - %s\n", t)
+		}
+	}
+}
+
+func runError(err *domain.RunError) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
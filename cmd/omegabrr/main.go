@@ -37,6 +37,12 @@ Commands:
   arr            Run omegabrr arr once
   lists          Run omegabrr lists once
   run            Run omegabrr service on schedule
+  history        List recent runs, or show a single run with history <id>
+  config         Manage the configuration file:
+                   validate  Check the config file and list every problem found
+                   schema    Print the JSON Schema of the config file
+                   migrate   Upgrade the config file to the current version, keeping a .bak copy
+                   show      Print the config as loaded, after includes, env vars and defaults (--redacted masks secrets)
   generate-token Generate an API Token (optionally call with --length <number>)
   version        Print version info
   update         Update omegabrr to latest version.
@@ -46,6 +52,19 @@ Flags:
   -c, --config <path>  Path to configuration file (default is $OMEGABRR_CONFIG, or config.yaml in the default user config directory)
   --dry-run            Dry-run without inserting filters (default false)
   --length <number>    Length of the generated API token (default 16)
+  --limit <number>     Number of runs listed by history (default 20)
+  --output <format>    Format of the arr and lists run report: table or json (default table)
+  --redacted           Mask apikeys, passwords and tokens in config show (default false)
+
+Exit codes of arr and lists:
+  0  all sources fetched and filters updated
+  1  run failed for another reason, e.g. it was cancelled
+  2  invalid source configuration
+  3  a source could not be fetched
+  4  a filter could not be updated
+  5  a source or the run timed out
+  When a run fails for several reasons, the lowest code wins,
+  so a cancelled run exits with 1 even when sources failed too.
 
 Provide a configuration file using one of the following methods:
 1. Use the --config <path> or -c <path> flag.
@@ -70,6 +89,9 @@ func main() {
 
 	// Define and parse flags using pflag
 	length := pflag.Int("length", 16, "length of the generated API token")
+	limit := pflag.Int("limit", 20, "number of runs listed by history")
+	output := pflag.String("output", outputTable, "output format of the arr and lists run report: table or json")
+	redacted := pflag.Bool("redacted", false, "mask apikeys, passwords and tokens in config show")
 	pflag.Parse()
 
 	if configPath == "" {
@@ -170,38 +192,92 @@ func main() {
 		}
 		fmt.Fprintf(os.Stdout, "API Token: %v\nCopy and paste into your config file config.yaml\n", key)
 	case "arr":
+		if err := checkOutput(*output); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(exitFailed)
+		}
+
 		cfg := domain.NewConfig(configPath)
 
 		p := processor.NewService(cfg)
+		if store := openHistory(cfg); store != nil {
+			p.SetStore(store)
+		}
+
 		ctx := context.Background()
-		errors := p.ProcessArrs(ctx, dryRun)
-		if len(errors) == 0 {
+		run := p.ProcessArrs(ctx, domain.RunTriggerCLI, dryRun)
+		if err := printReport(os.Stdout, run, *output); err != nil {
+			log.Error().Err(err).Msg("could not print run report")
+		}
+
+		if errs := run.Errors(); len(errs) == 0 {
 			log.Info().Msg("Run complete.")
 		} else {
 			log.Warn().Msg("Run complete, with errors.")
 			log.Warn().Msg("Errors encountered during processing:")
-			for _, err := range errors {
+			for _, err := range errs {
 				log.Warn().Msg(err)
 			}
-			os.Exit(1)
 		}
+		os.Exit(exitCode(run))
 
 	case "lists":
+		if err := checkOutput(*output); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(exitFailed)
+		}
+
 		cfg := domain.NewConfig(configPath)
 
 		p := processor.NewService(cfg)
+		if store := openHistory(cfg); store != nil {
+			p.SetStore(store)
+		}
+
 		ctx := context.Background()
-		errors := p.ProcessLists(ctx, dryRun)
-		if len(errors) == 0 {
+		run := p.ProcessLists(ctx, domain.RunTriggerCLI, dryRun)
+		if err := printReport(os.Stdout, run, *output); err != nil {
+			log.Error().Err(err).Msg("could not print run report")
+		}
+
+		if errs := run.Errors(); len(errs) == 0 {
 			log.Info().Msg("Run complete.")
 		} else {
 			log.Warn().Msg("Run complete, with errors.")
 			log.Warn().Msg("Errors encountered during processing:")
-			for _, err := range errors {
+			for _, err := range errs {
 				log.Warn().Msg(err)
 			}
+		}
+		os.Exit(exitCode(run))
+
+	case "config":
+		os.Exit(runConfigCommand(os.Stdout, pflag.Arg(1), configPath, *redacted))
+
+	case "history":
+		cfg := domain.NewConfig(configPath)
+
+		store := openHistory(cfg)
+		if store == nil {
+			os.Exit(1)
+		}
+
+		if id := pflag.Arg(1); id != "" {
+			run, err := store.GetRun(id)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "could not get run %s: %v\n", id, err)
+				os.Exit(1)
+			}
+			printRun(os.Stdout, run)
+			break
+		}
+
+		runs, err := store.ListRuns(*limit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not list runs: %v\n", err)
 			os.Exit(1)
 		}
+		printRuns(os.Stdout, runs)
 
 	case "run":
 		cfg := domain.NewConfig(configPath)
@@ -211,9 +287,17 @@ func main() {
 
 		p := processor.NewService(cfg)
 
-		schedulerService := scheduler.NewService(cfg, p)
+		store := openHistory(cfg)
+		if store != nil {
+			p.SetStore(store)
+		}
+
+		coordinator := scheduler.NewCoordinator(p, cfg.Processing.Debounce)
+		schedulerService := scheduler.NewService(cfg, coordinator)
 
-		srv := http.NewServer(cfg, p)
+		holder := domain.NewConfigHolder(cfg)
+
+		srv := http.NewServer(holder, p, coordinator, schedulerService, store)
 
 		errorChannel := make(chan error)
 		go func() {
@@ -225,41 +309,30 @@ func main() {
 
 		schedulerService.Start()
 
-		go func() {
-			log.Debug().Msgf("sleeping 15 seconds before running...")
-
-			time.Sleep(15 * time.Second)
-
-			ctx := context.Background()
-
-			// Store processing errors for ProcessArrs and ProcessLists
-			var processingErrors []string
+		reloader := &configReloader{
+			path:        configPath,
+			holder:      holder,
+			processor:   p,
+			coordinator: coordinator,
+			scheduler:   schedulerService,
+		}
 
-			arrsErrors := p.ProcessArrs(ctx, false)
-			if len(arrsErrors) > 0 {
-				processingErrors = append(processingErrors, arrsErrors...)
+		if configPath != "" {
+			if err := reloader.watch(context.Background()); err != nil {
+				log.Warn().Err(err).Msg("config changes are only picked up on SIGHUP")
 			}
+		}
 
-			listsErrors := p.ProcessLists(ctx, false)
-			if len(listsErrors) > 0 {
-				processingErrors = append(processingErrors, listsErrors...)
-			}
+		for sig := range sigCh {
+			log.Info().Msgf("Received signal: %v", sig)
 
-			// Print the summary of potential errors
-			if len(processingErrors) == 0 {
-				log.Info().Msg("Run complete.")
-			} else {
-				log.Warn().Msg("Run complete, with errors.")
-				log.Warn().Msg("Errors encountered during processing:")
-				for _, errMsg := range processingErrors {
-					log.Warn().Msg(errMsg)
+			if sig == syscall.SIGHUP {
+				if configPath != "" {
+					reloader.reloadAndLog("received SIGHUP")
 				}
+				continue
 			}
 
-		}()
-
-		for sig := range sigCh {
-			log.Info().Msgf("Received signal: %v", sig)
 			schedulerService.Stop()
 			os.Exit(0)
 		}
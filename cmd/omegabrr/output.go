@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+)
+
+// Exit codes of the arr and lists commands, so wrappers can tell failures apart.
+const (
+	exitOK      = 0
+	exitFailed  = 1
+	exitConfig  = 2
+	exitFetch   = 3
+	exitUpdate  = 4
+	exitTimeout = 5
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// checkOutput returns an error for output formats printReport does not support,
+// so a bad --output is rejected before a run updates any filters.
+func checkOutput(output string) error {
+	switch output {
+	case outputTable, outputJSON, "":
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format: %q, must be %s or %s", output, outputTable, outputJSON)
+	}
+}
+
+// printReport writes the run report in the requested output format.
+func printReport(w io.Writer, run *domain.RunReport, output string) error {
+	switch output {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(run)
+	case outputTable, "":
+		printRun(w, run)
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format: %q", output)
+	}
+}
+
+// exitCode maps the errors of a run to an exit code. When a run failed for
+// several reasons, the lowest code wins, so a cancelled run exits with 1 even if
+// some of its sources failed as well.
+func exitCode(run *domain.RunReport) int {
+	classes := run.ErrorClasses()
+	if len(classes) == 0 {
+		return exitOK
+	}
+
+	codes := map[domain.ErrorClass]int{
+		domain.ErrorClassConfig:  exitConfig,
+		domain.ErrorClassFetch:   exitFetch,
+		domain.ErrorClassUpdate:  exitUpdate,
+		domain.ErrorClassTimeout: exitTimeout,
+	}
+
+	lowest := 0
+	for class := range classes {
+		code, ok := codes[class]
+		if !ok {
+			// cancelled, or a class without its own code
+			code = exitFailed
+		}
+		if lowest == 0 || code < lowest {
+			lowest = code
+		}
+	}
+
+	return lowest
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_exitCode(t *testing.T) {
+	report := func(classes ...domain.ErrorClass) *domain.RunReport {
+		run := &domain.RunReport{}
+		for _, class := range classes {
+			run.Sources = append(run.Sources, domain.SourceReport{Error: &domain.RunError{Class: class}})
+		}
+		return run
+	}
+
+	tests := []struct {
+		name string
+		run  *domain.RunReport
+		want int
+	}{
+		{name: "ok", run: report(), want: exitOK},
+		{name: "fetch", run: report(domain.ErrorClassFetch), want: exitFetch},
+		{name: "fetch and timeout", run: report(domain.ErrorClassTimeout, domain.ErrorClassFetch), want: exitFetch},
+		{name: "config and update", run: report(domain.ErrorClassUpdate, domain.ErrorClassConfig), want: exitConfig},
+		{name: "cancelled and fetch", run: report(domain.ErrorClassFetch, domain.ErrorClassCancelled, domain.ErrorClassConfig), want: exitFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, exitCode(tt.run))
+		})
+	}
+}
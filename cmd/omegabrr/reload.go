@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+	"github.com/autobrr/omegabrr/internal/processor"
+	"github.com/autobrr/omegabrr/internal/scheduler"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// reloadDelay waits for an editor to finish writing before the config is reloaded.
+const reloadDelay = 500 * time.Millisecond
+
+// configReloader loads the config file again when it changes, and swaps it in for
+// the running services if it is valid.
+type configReloader struct {
+	path        string
+	holder      *domain.ConfigHolder
+	processor   *processor.Service
+	coordinator *scheduler.Coordinator
+	scheduler   *scheduler.Service
+
+	mu      sync.Mutex
+	watcher *fsnotify.Watcher
+}
+
+func (r *configReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := domain.LoadConfig(r.path)
+	if err != nil {
+		return err
+	}
+
+	if err := r.processor.SetConfig(cfg); err != nil {
+		return errors.Wrap(err, "invalid config")
+	}
+
+	previous := r.holder.Get()
+	if previous.Server.Host != cfg.Server.Host || previous.Server.Port != cfg.Server.Port {
+		log.Warn().Msg("server host and port changes take effect after a restart")
+	}
+	if previous.StateDir != cfg.StateDir {
+		log.Warn().Msg("stateDir changes take effect after a restart")
+	}
+
+	r.holder.Set(cfg)
+	r.coordinator.SetDebounce(cfg.Processing.Debounce)
+	r.scheduler.Reload(cfg)
+
+	if r.watcher != nil {
+		r.watchIncludes(cfg)
+	}
+
+	return nil
+}
+
+// reloadAndLog reloads the config, keeping the current one if the new one is invalid.
+func (r *configReloader) reloadAndLog(reason string) {
+	log.Info().Msgf("reloading config: %s", reason)
+
+	if err := r.reload(); err != nil {
+		log.Error().Err(err).Msg("could not reload config, keeping the current one")
+		return
+	}
+
+	log.Info().Msg("config reloaded")
+}
+
+// watch reloads the config whenever the file or one of the included files is
+// written. Directories are watched rather than files, since editors replace files
+// instead of writing to them, and Kubernetes swaps the ..data symlink they point
+// through.
+func (r *configReloader) watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "could not create config watcher")
+	}
+
+	if err := watcher.Add(filepath.Dir(r.path)); err != nil {
+		watcher.Close()
+		return errors.Wrapf(err, "could not watch %q", r.path)
+	}
+
+	r.mu.Lock()
+	r.watcher = watcher
+	r.watchIncludes(r.holder.Get())
+	r.mu.Unlock()
+
+	go func() {
+		defer watcher.Close()
+
+		var timer *time.Timer
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !ev.Has(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) || !r.isConfigFile(ev.Name) {
+					continue
+				}
+
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(reloadDelay, func() {
+					r.reloadAndLog(fmt.Sprintf("%s changed", filepath.Base(ev.Name)))
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Warn().Err(err).Msg("config watcher error")
+			}
+		}
+	}()
+
+	return nil
+}
+
+// watchIncludes watches the directories of the include patterns too. Directories
+// already watched are skipped by fsnotify.
+func (r *configReloader) watchIncludes(cfg *domain.Config) {
+	for _, pattern := range cfg.Include {
+		if err := r.watcher.Add(filepath.Dir(pattern)); err != nil {
+			log.Warn().Err(err).Msgf("could not watch included %q", pattern)
+		}
+	}
+}
+
+// kubernetesDataDir is the symlink a Kubernetes ConfigMap or Secret volume points
+// its files through. An update swaps the symlink, so the files themselves see no events.
+const kubernetesDataDir = "..data"
+
+// isConfigFile reports whether name is the config file, matches an include pattern,
+// or is the data symlink of a Kubernetes volume.
+func (r *configReloader) isConfigFile(name string) bool {
+	name = filepath.Clean(name)
+	if name == filepath.Clean(r.path) || filepath.Base(name) == kubernetesDataDir {
+		return true
+	}
+
+	for _, pattern := range r.holder.Get().Include {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -3,8 +3,11 @@ package domain
 import (
 	"bytes"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/autobrr/omegabrr/internal/apitoken"
 
@@ -16,8 +19,10 @@ import (
 )
 
 type BasicAuth struct {
-	User string `koanf:"user"`
-	Pass string `koanf:"pass"`
+	User        string `koanf:"user"`
+	Pass        string `koanf:"pass"`
+	PassFile    string `koanf:"passFile"`
+	PassCommand string `koanf:"passCommand"`
 }
 
 type ListConfig struct {
@@ -29,6 +34,17 @@ type ListConfig struct {
 	MatchRelease bool              `koanf:"matchRelease"`
 	Album        bool              `koanf:"album"`
 	Headers      map[string]string `koanf:"headers"`
+	// HeaderFiles and HeaderCommands set headers by name from a file or the output of a command.
+	HeaderFiles    map[string]string `koanf:"headerFiles"`
+	HeaderCommands map[string]string `koanf:"headerCommands"`
+	Schedule       string            `koanf:"schedule"`
+	// Enabled is true when not set. Disabled lists are reported as skipped.
+	Enabled *bool `koanf:"enabled"`
+}
+
+// IsEnabled reports whether the list is processed.
+func (c *ListConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
 }
 
 type ListType string
@@ -46,6 +62,8 @@ type ArrConfig struct {
 	Type                   ArrType    `koanf:"type"`
 	Host                   string     `koanf:"host"`
 	Apikey                 string     `koanf:"apikey"`
+	ApikeyFile             string     `koanf:"apikeyFile"`
+	ApikeyCommand          string     `koanf:"apikeyCommand"`
 	BasicAuth              *BasicAuth `koanf:"basicAuth"`
 	Filters                []int      `koanf:"filters"`
 	TagsInclude            []string   `koanf:"tagsInclude"`
@@ -53,6 +71,44 @@ type ArrConfig struct {
 	MatchRelease           bool       `koanf:"matchRelease"`
 	ExcludeAlternateTitles bool       `koanf:"excludeAlternateTitles"`
 	IncludeUnmonitored     bool       `koanf:"includeUnmonitored"`
+	Schedule               string     `koanf:"schedule"`
+	// Enabled is true when not set. Disabled arrs are reported as skipped.
+	Enabled *bool `koanf:"enabled"`
+
+	// Emit is what a Lidarr, Readarr or Whisparr v3 arr writes to its filters, see
+	// DefaultArrEmits when empty.
+	Emit []ArrEmit `koanf:"emit"`
+	// AlbumTypes are the Lidarr primary and secondary album types to include, all when empty.
+	AlbumTypes []AlbumType `koanf:"albumTypes"`
+	// ReleasedWithin skips Lidarr albums released longer ago. Upcoming albums are kept.
+	ReleasedWithin time.Duration `koanf:"releasedWithin"`
+	// VariousArtists and FeaturedArtists control Lidarr albums and artists credited to
+	// Various Artists or with featured artists, like "Title (feat. Someone)".
+	VariousArtists  CreditMode `koanf:"variousArtists"`
+	FeaturedArtists CreditMode `koanf:"featuredArtists"`
+	// QualityProfiles and MetadataProfiles only include Readarr authors and their books
+	// with one of these profiles, like eBook or Spoken, by name.
+	QualityProfiles  []string `koanf:"qualityProfiles"`
+	MetadataProfiles []string `koanf:"metadataProfiles"`
+	// WhisparrVersion is 2 for the Sonarr based Whisparr and 3 for the movie and scene
+	// based one. It is detected from the system status when not set.
+	WhisparrVersion int `koanf:"whisparrVersion"`
+}
+
+// IsEnabled reports whether the arr is processed.
+func (c *ArrConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// Emits reports whether the arr writes emit to its filters, falling back to
+// DefaultArrEmits of its type when emit isn't set.
+func (c *ArrConfig) Emits(emit ArrEmit) bool {
+	emits := c.Emit
+	if len(emits) == 0 {
+		emits = DefaultArrEmits[c.Type]
+	}
+
+	return oneOf(emit, emits)
 }
 
 type ArrType string
@@ -65,24 +121,139 @@ var (
 	ArrTypeWhisparr ArrType = "whisparr"
 )
 
+// ArrEmit is something an arr source can write to its filters.
+type ArrEmit string
+
+var (
+	ArrEmitAlbums       ArrEmit = "albums"
+	ArrEmitArtists      ArrEmit = "artists"
+	ArrEmitTitles       ArrEmit = "titles"
+	ArrEmitAuthorTitles ArrEmit = "authorTitles"
+	ArrEmitAuthors      ArrEmit = "authors"
+	ArrEmitSeries       ArrEmit = "series"
+	ArrEmitSites        ArrEmit = "sites"
+	ArrEmitPerformers   ArrEmit = "performers"
+)
+
+// AlbumType is a Lidarr (MusicBrainz) primary or secondary album type.
+type AlbumType string
+
+// CreditMode is how to handle albums and artists with a particular credit.
+type CreditMode string
+
+var (
+	// CreditModeKeep uses them as they are in the arr, it is the default.
+	CreditModeKeep CreditMode = "keep"
+	// CreditModeNormalize writes them the way releases are usually named.
+	CreditModeNormalize CreditMode = "normalize"
+	// CreditModeSkip leaves them out.
+	CreditModeSkip CreditMode = "skip"
+)
+
+// SetConfig combines the titles of other arrs and lists by name with a set operation.
+// Sources are applied left to right, so difference keeps what is in the first source
+// but in none of the others.
+type SetConfig struct {
+	Name         string       `koanf:"name"`
+	Operation    SetOperation `koanf:"operation"`
+	Sources      []string     `koanf:"sources"`
+	Filters      []int        `koanf:"filters"`
+	MatchRelease bool         `koanf:"matchRelease"`
+	Schedule     string       `koanf:"schedule"`
+	// Enabled is true when not set. Disabled sets are reported as skipped.
+	Enabled *bool `koanf:"enabled"`
+}
+
+// IsEnabled reports whether the set is processed.
+func (c *SetConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+type SetOperation string
+
+var (
+	SetOperationUnion        SetOperation = "union"
+	SetOperationIntersection SetOperation = "intersection"
+	SetOperationDifference   SetOperation = "difference"
+)
+
 type AutobrrConfig struct {
-	Host      string     `koanf:"host"`
-	Apikey    string     `koanf:"apikey"`
-	BasicAuth *BasicAuth `koanf:"basicAuth"`
+	Host          string     `koanf:"host"`
+	Apikey        string     `koanf:"apikey"`
+	ApikeyFile    string     `koanf:"apikeyFile"`
+	ApikeyCommand string     `koanf:"apikeyCommand"`
+	BasicAuth     *BasicAuth `koanf:"basicAuth"`
+}
+
+// ProcessingConfig bounds how sources are processed during a run.
+type ProcessingConfig struct {
+	// Concurrency is the number of sources fetched at the same time.
+	Concurrency int `koanf:"concurrency"`
+	// SourceTimeout is how long a single arr or list may take.
+	SourceTimeout time.Duration `koanf:"sourceTimeout"`
+	// RunTimeout cancels any sources still running once a run takes this long.
+	RunTimeout time.Duration `koanf:"runTimeout"`
+	// SkipUnchanged skips filter updates when the titles are the same as before.
+	SkipUnchanged bool `koanf:"skipUnchanged"`
+	// CompareRemote compares with the filter in autobrr instead of the previous run.
+	// Without it, edits made in the autobrr web UI stay until the titles change.
+	CompareRemote bool `koanf:"compareRemote"`
+	// Debounce merges async webhook triggers arriving within this window into one run.
+	Debounce time.Duration `koanf:"debounce"`
+}
+
+// SchedulerConfig controls when scheduled runs happen.
+type SchedulerConfig struct {
+	// RunOnStart processes everything once when the service starts.
+	RunOnStart bool `koanf:"runOnStart"`
+	// StartDelay is how long to wait before the run on start.
+	StartDelay time.Duration `koanf:"startDelay"`
+	// Jitter delays every scheduled run by a random duration up to this long.
+	Jitter time.Duration `koanf:"jitter"`
+	// Timezone the schedules are in, like Europe/Berlin. Local time when empty.
+	Timezone string `koanf:"timezone"`
+}
+
+// DefaultsConfig holds the settings every arr and list inherits unless it sets them itself.
+type DefaultsConfig struct {
+	Arr   *ArrConfig  `koanf:"arr"`
+	Lists *ListConfig `koanf:"lists"`
+}
+
+// HistoryConfig controls the run history kept in the state directory.
+type HistoryConfig struct {
+	// Retention is the number of runs to keep.
+	Retention int `koanf:"retention"`
 }
 
 type Config struct {
-	Server struct {
+	// Version is the layout of the config file. Older layouts are migrated when loaded.
+	Version int `koanf:"version"`
+	// Include are glob patterns of files with more arrs, lists and sets, like conf.d/*.yaml.
+	// They are relative to the config file when loaded, and absolute after.
+	Include []string `koanf:"include"`
+	Server  struct {
 		Host     string `koanf:"host"`
 		Port     int    `koanf:"port"`
 		APIToken string `koanf:"apiToken"`
 	} `koanf:"server"`
-	Schedule string `koanf:"schedule"`
-	Clients  struct {
+	Schedule   string           `koanf:"schedule"`
+	Scheduler  SchedulerConfig  `koanf:"scheduler"`
+	StateDir   string           `koanf:"stateDir"`
+	History    HistoryConfig    `koanf:"history"`
+	Processing ProcessingConfig `koanf:"processing"`
+	Defaults   DefaultsConfig   `koanf:"defaults"`
+	Clients    struct {
 		Autobrr *AutobrrConfig `koanf:"autobrr"`
 		Arr     []*ArrConfig   `koanf:"arr"`
 	} `koanf:"clients"`
 	Lists []*ListConfig `koanf:"lists"`
+	Sets  []*SetConfig  `koanf:"sets"`
+
+	// file is the path of the config file, and includes are the arrs, lists and sets
+	// from included files, by their path.
+	file     string
+	includes map[string]includedEntry
 }
 
 func (c *Config) defaults() {
@@ -91,82 +262,186 @@ func (c *Config) defaults() {
 
 	c.Schedule = "0 */6 * * *"
 
+	c.Scheduler.RunOnStart = true
+	c.Scheduler.StartDelay = 15 * time.Second
+
+	c.History.Retention = 100
+
+	c.Processing.Concurrency = 4
+	c.Processing.SourceTimeout = 5 * time.Minute
+	c.Processing.RunTimeout = 30 * time.Minute
+	c.Processing.SkipUnchanged = true
+	c.Processing.Debounce = 30 * time.Second
+
 	c.Clients.Autobrr = nil
 	c.Clients.Arr = nil
 	c.Lists = nil
+	c.Sets = nil
 }
 
-var k = koanf.New(".")
+// NewConfig loads the config file, writing a default one if it doesn't exist yet.
+// Errors are fatal.
+func NewConfig(configPath string) *Config {
+	if configPath == "" {
+		cfg := &Config{}
+		cfg.defaults()
+		return cfg
+	}
+
+	// create config if it doesn't exist
+	if _, err := os.Stat(configPath); errors.Is(err, os.ErrNotExist) {
+		cfg := &Config{}
+		cfg.defaults()
+
+		if writeErr := cfg.writeFile(configPath); writeErr != nil {
+			log.Fatal().
+				Err(writeErr).
+				Str("service", "config").
+				Msgf("failed writing %q", configPath)
+		}
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		var validationErrs ValidationErrors
+		if errors.As(err, &validationErrs) {
+			for _, e := range validationErrs {
+				log.Error().Str("service", "config").Msg(e.Error())
+			}
+		}
 
-func validateConfig(condition bool, field, entityType, entityName string) {
-	if condition {
 		log.Fatal().
+			Err(err).
 			Str("service", "config").
-			Msgf("%s not set for %s: %s", field, entityType, entityName)
+			Msgf("failed loading %q", configPath)
 		os.Exit(1)
 	}
+
+	return cfg
 }
 
-func NewConfig(configPath string) *Config {
+// LoadConfig reads and validates the config file. Every call starts from the
+// defaults, so it can be used to reload the config of a running service. All
+// problems found are returned together as ValidationErrors.
+func LoadConfig(configPath string) (*Config, error) {
 	cfg := &Config{}
 
 	cfg.defaults()
 
-	if configPath != "" {
-		// create config if it doesn't exist
-		if _, err := os.Stat(configPath); errors.Is(err, os.ErrNotExist) {
-			if writeErr := cfg.writeFile(configPath); writeErr != nil {
-				log.Fatal().
-					Err(writeErr).
-					Str("service", "config").
-					Msgf("failed writing %q", configPath)
-			}
-		}
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading %q", configPath)
+	}
 
-		content, err := os.ReadFile(configPath)
-		if err != nil {
-			log.Fatal().
-				Err(err).
-				Str("service", "config").
-				Msgf("failed reading %q", configPath)
-		}
+	// upgrade older layouts, so the rest only deals with the current one
+	content, version, warnings, err := MigrateConfig(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed migrating %q", configPath)
+	}
+	for _, w := range warnings {
+		log.Warn().Str("service", "config").Msgf("%s: %s", configPath, w)
+	}
+	if version < ConfigVersion {
+		log.Warn().Str("service", "config").Msgf("%s: config is at version %d, run omegabrr config migrate to upgrade it to version %d", configPath, version, ConfigVersion)
+	}
 
-		// expand env vars
-		expandedContent := os.ExpandEnv(string(content))
-		provider := rawbytes.Provider([]byte(expandedContent))
+	for _, w := range UnknownKeys(content) {
+		log.Warn().Str("service", "config").Msgf("%s: %s", configPath, w)
+	}
 
-		// load
-		if err := k.Load(provider, yaml.Parser()); err != nil {
-			log.Fatal().
-				Err(err).
-				Str("service", "config").
-				Msgf("failed parsing %q", configPath)
-		}
+	// expand env vars
+	expandedContent := os.ExpandEnv(string(content))
+	provider := rawbytes.Provider([]byte(expandedContent))
 
-		// unmarshal
-		if err := k.Unmarshal("", &cfg); err != nil {
-			log.Fatal().
-				Err(err).
-				Str("service", "config").
-				Msgf("failed unmarshalling %q", configPath)
-		}
+	k := koanf.New(".")
+
+	// load
+	if err := k.Load(provider, yaml.Parser()); err != nil {
+		return nil, errors.Wrapf(err, "failed parsing %q", configPath)
+	}
+
+	// merge arrs, lists and sets from included files
+	includes, includeErrs, err := loadIncludes(k, filepath.Dir(configPath))
+	if err != nil {
+		return nil, err
+	}
+
+	// override with OMEGABRR_ environment variables
+	envWarnings, err := loadEnv(k)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range envWarnings {
+		log.Warn().Str("service", "config").Msg(w.Error())
+	}
+
+	// fill in what arrs and lists don't set themselves
+	if err := applyDefaults(k); err != nil {
+		return nil, err
+	}
+
+	// unmarshal
+	if err := k.Unmarshal("", &cfg); err != nil {
+		return nil, errors.Wrapf(err, "failed unmarshalling %q", configPath)
+	}
 
-		for _, list := range cfg.Lists {
-			validateConfig(len(list.Filters) < 1, "Filters", "list", list.Name)
-			validateConfig(list.URL == "", "URL", "list", list.Name)
-			validateConfig(list.Type == "", "Type", "list", list.Name)
+	cfg.file = configPath
+	cfg.includes = includes
+	for i, pattern := range cfg.Include {
+		if !filepath.IsAbs(pattern) {
+			cfg.Include[i] = filepath.Join(filepath.Dir(configPath), pattern)
 		}
+	}
+
+	// keep state next to the config file unless told otherwise
+	if cfg.StateDir == "" {
+		cfg.StateDir = "state"
+	}
+	if !filepath.IsAbs(cfg.StateDir) {
+		cfg.StateDir = filepath.Join(filepath.Dir(configPath), cfg.StateDir)
+	}
+
+	errs := validateEnv(content)
+	errs = append(errs, includeErrs...)
+	errs = append(errs, cfg.resolveSecrets(filepath.Dir(configPath))...)
+	errs = append(errs, cfg.Validate()...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
 
-		for _, arr := range cfg.Clients.Arr {
-			validateConfig(len(arr.Filters) < 1, "Filters", "arr", arr.Name)
-			validateConfig(arr.Host == "", "Host", "arr", arr.Name)
-			validateConfig(arr.Apikey == "", "API", "arr", arr.Name)
-			validateConfig(arr.Type == "", "Type", "arr", arr.Name)
+	return cfg, nil
+}
+
+// SourceSchedules groups the names of all enabled arrs, lists and sets by their
+// cron schedule, falling back to the global schedule.
+func (c *Config) SourceSchedules() map[string][]string {
+	schedules := make(map[string][]string)
+
+	add := func(name, schedule string) {
+		if schedule == "" {
+			schedule = c.Schedule
 		}
+		schedules[schedule] = append(schedules[schedule], name)
+	}
 
+	// disabled entries would only be reported as skipped
+	for _, arr := range c.Clients.Arr {
+		if arr.IsEnabled() {
+			add(arr.Name, arr.Schedule)
+		}
+	}
+	for _, list := range c.Lists {
+		if list.IsEnabled() {
+			add(list.Name, list.Schedule)
+		}
+	}
+	for _, set := range c.Sets {
+		if set.IsEnabled() {
+			add(set.Name, set.Schedule)
+		}
 	}
 
-	return cfg
+	return schedules
 }
 
 func (c *Config) writeFile(configPath string) error {
@@ -209,6 +484,7 @@ func (c *Config) writeFile(configPath string) error {
 	}
 
 	tmplVars := map[string]string{
+		"version":  strconv.Itoa(ConfigVersion),
 		"host":     host,
 		"apiToken": token,
 	}
@@ -227,19 +503,42 @@ func (c *Config) writeFile(configPath string) error {
 }
 
 var configTemplate = `# config.yaml
+# yaml-language-server: $schema=https://raw.githubusercontent.com/autobrr/omegabrr/main/config.schema.json
 ---
+version: {{ .version }} # layout of this file, upgrade older files with omegabrr config migrate
 server:
   host: {{ .host }}
   port: 7441
   apiToken: {{ .apiToken }}
 schedule: 0 */6 * * *
+#include: conf.d/*.yaml # more arrs, lists and sets, relative to this file
+#scheduler:
+#  runOnStart: true # process everything once when the service starts
+#  startDelay: 15s
+#  jitter: 0s # delay scheduled runs by a random duration up to this long
+#  timezone: "" # like Europe/Berlin, defaults to local time
+#stateDir: state # run history, relative to this file
+#history:
+#  retention: 100 # number of runs to keep
+#processing:
+#  concurrency: 4 # number of arrs and lists fetched at the same time
+#  sourceTimeout: 5m
+#  runTimeout: 30m
+#  skipUnchanged: true # don't update filters when the titles are unchanged
+#  compareRemote: false # compare with the filter in autobrr, needed to undo edits made in the web UI
+#  debounce: 30s # async webhook triggers within this window are merged into one run
+#defaults: # inherited by every arr and list that doesn't set them itself
+#  arr:
+#    includeUnmonitored: false
+#  lists:
+#    matchRelease: false
 clients:
   autobrr:
   #  host: http://localhost:7474
-  #  apikey: API_KEY
+  #  apikey: API_KEY # or apikeyFile: /run/secrets/autobrr_apikey, or apikeyCommand
   #  basicAuth:
   #    user: username
-  #    pass: password
+  #    pass: password # or passFile, or passCommand
 
   arr:
     #- name: radarr
@@ -266,6 +565,8 @@ clients:
     #    - 14 # Change me
     #  includeUnmonitored: false # Set to true to include unmonitored items
     #  #excludeAlternateTitles: true # defaults to false
+    #  #enabled: false # skip this arr without removing it
+    #  #schedule: "*/15 * * * *" # defaults to the global schedule
 
     #- name: readarr
     #  type: readarr
@@ -273,6 +574,8 @@ clients:
     #  apikey: API_KEY
     #  filters:
     #    - 18 # Change me
+    #  #emit: [titles] # titles, authorTitles ("Author - Title"), authors and series
+    #  #qualityProfiles: [eBook] # only authors with these profiles, to split ebooks and audiobooks
 
     #- name: lidarr
     #  type: lidarr
@@ -280,6 +583,11 @@ clients:
     #  apikey: API_KEY
     #  filters:
     #    - 32 # Change me
+    #  #emit: [albums, artists] # or only artists, for every monitored artist
+    #  #albumTypes: [Album, EP] # defaults to all album types
+    #  #releasedWithin: 2160h # only albums released in the last 90 days, or upcoming
+    #  #variousArtists: keep # keep, normalize (to VA) or skip
+    #  #featuredArtists: keep # keep, normalize (remove feat. credits) or skip
 
     #- name: whisparr
     #  type: whisparr
@@ -288,6 +596,8 @@ clients:
     #  matchRelease: true
     #  filters:
     #    - 69 # Change me
+    #  #whisparrVersion: 3 # 2 or 3, detected when left out
+    #  #emit: [titles] # titles, and with v3 also sites and performers
 
 lists:
   #- name: Latest TV Shows
@@ -331,6 +641,7 @@ lists:
   #  url: https://api.autobrr.com/lists/metacritic/new-albums
   #  filters:
   #    - 9 # Change me
+  #  #schedule: 0 3 * * * # defaults to the global schedule
 
   #- name: Upcoming Albums
   #  type: metacritic
@@ -343,4 +654,13 @@ lists:
   #  url: https://store.steampowered.com/wishlist/id/USERNAME/wishlistdata
   #  filters:
   #    - 20 # Change me
+
+sets:
+  #- name: Upcoming Movies not in Radarr
+  #  operation: difference # union, intersection or difference
+  #  sources:
+  #    - Upcoming Movies
+  #    - radarr
+  #  filters:
+  #    - 26 # Change me
 `
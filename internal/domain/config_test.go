@@ -0,0 +1,450 @@
+package domain
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validConfig() *Config {
+	cfg := &Config{
+		Schedule: "0 */6 * * *",
+		Lists: []*ListConfig{
+			{Name: "trakt", Type: ListTypeTrakt, URL: "https://api.autobrr.com/lists/trakt/popular-tv"},
+		},
+		Sets: []*SetConfig{
+			{Name: "all", Operation: SetOperationUnion, Sources: []string{"radarr", "trakt"}, Filters: []int{2}},
+		},
+	}
+	cfg.Clients.Autobrr = &AutobrrConfig{Host: "http://localhost:7474", Apikey: "key"}
+	cfg.Clients.Arr = []*ArrConfig{
+		{Name: "radarr", Type: ArrTypeRadarr, Host: "http://localhost:7878", Apikey: "key", Filters: []int{1}},
+	}
+
+	return cfg
+}
+
+func paths(errs ValidationErrors) []string {
+	p := make([]string, 0, len(errs))
+	for _, err := range errs {
+		p = append(p, err.Path)
+	}
+	return p
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		assert.Empty(t, validConfig().Validate())
+	})
+
+	t.Run("reports every problem", func(t *testing.T) {
+		cfg := validConfig()
+		cfg.Schedule = "*/5 * * *"
+		cfg.Clients.Arr[0].Type = "radar"
+		cfg.Clients.Arr = append(cfg.Clients.Arr, &ArrConfig{Name: "trakt", Type: ArrTypeSonarr, Host: "localhost:8989", Filters: []int{3}})
+		cfg.Sets[0].Sources = append(cfg.Sets[0].Sources, "missing")
+
+		assert.Equal(t, []string{
+			"schedule",
+			"clients.arr[0].type",
+			"clients.arr[1].host",
+			"clients.arr[1].apikey",
+			"lists[0].name",
+			"sets[0].sources[2]",
+		}, paths(cfg.Validate()))
+	})
+
+	t.Run("sets can't combine sets", func(t *testing.T) {
+		cfg := validConfig()
+		cfg.Sets = append(cfg.Sets, &SetConfig{
+			Name:      "nested",
+			Operation: SetOperationUnion,
+			Sources:   []string{cfg.Sets[0].Name, cfg.Clients.Arr[0].Name},
+			Filters:   []int{9},
+		})
+
+		assert.Equal(t, []string{
+			"sets[1].sources[0]",
+		}, paths(cfg.Validate()))
+	})
+
+	t.Run("lidarr options", func(t *testing.T) {
+		cfg := validConfig()
+		cfg.Clients.Arr[0].Emit = []ArrEmit{ArrEmitAlbums}
+		cfg.Clients.Arr[0].VariousArtists = CreditModeSkip
+		cfg.Clients.Arr = append(cfg.Clients.Arr, &ArrConfig{
+			Name:            "lidarr",
+			Type:            ArrTypeLidarr,
+			Host:            "http://localhost:8686",
+			Apikey:          "key",
+			Filters:         []int{3},
+			Emit:            []ArrEmit{ArrEmitArtists, "shows"},
+			AlbumTypes:      []AlbumType{"Album", "album"},
+			ReleasedWithin:  -time.Hour,
+			FeaturedArtists: "strip",
+		})
+
+		assert.Equal(t, []string{
+			"clients.arr[0].emit",
+			"clients.arr[0].variousArtists",
+			"clients.arr[1].emit[1]",
+			"clients.arr[1].albumTypes[1]",
+			"clients.arr[1].releasedWithin",
+			"clients.arr[1].featuredArtists",
+		}, paths(cfg.Validate()))
+	})
+
+	t.Run("lidarr artists in match releases", func(t *testing.T) {
+		cfg := validConfig()
+		cfg.Clients.Arr = append(cfg.Clients.Arr, &ArrConfig{
+			Name:         "lidarr",
+			Type:         ArrTypeLidarr,
+			Host:         "http://localhost:8686",
+			Apikey:       "key",
+			Filters:      []int{3},
+			MatchRelease: true,
+			Emit:         []ArrEmit{ArrEmitArtists},
+		}, &ArrConfig{
+			Name:         "lidarr-albums",
+			Type:         ArrTypeLidarr,
+			Host:         "http://localhost:8687",
+			Apikey:       "key",
+			Filters:      []int{4},
+			MatchRelease: true,
+		})
+
+		assert.Equal(t, []string{
+			"clients.arr[1].emit",
+		}, paths(cfg.Validate()))
+	})
+
+	t.Run("readarr options", func(t *testing.T) {
+		cfg := validConfig()
+		cfg.Clients.Arr[0].QualityProfiles = []string{"eBook"}
+		cfg.Clients.Arr = append(cfg.Clients.Arr, &ArrConfig{
+			Name:             "readarr",
+			Type:             ArrTypeReadarr,
+			Host:             "http://localhost:8787",
+			Apikey:           "key",
+			Filters:          []int{3},
+			Emit:             []ArrEmit{ArrEmitAuthorTitles, ArrEmitAlbums},
+			MetadataProfiles: []string{"Standard"},
+		})
+
+		assert.Equal(t, []string{
+			"clients.arr[0].qualityProfiles",
+			"clients.arr[1].emit[1]",
+		}, paths(cfg.Validate()))
+	})
+
+	t.Run("whisparr options", func(t *testing.T) {
+		cfg := validConfig()
+		cfg.Clients.Arr[0].WhisparrVersion = 3
+		cfg.Clients.Arr = append(cfg.Clients.Arr, &ArrConfig{
+			Name:            "whisparr",
+			Type:            ArrTypeWhisparr,
+			Host:            "http://localhost:6969",
+			Apikey:          "key",
+			Filters:         []int{3},
+			WhisparrVersion: 4,
+		}, &ArrConfig{
+			Name:            "whisparr-v2",
+			Type:            ArrTypeWhisparr,
+			Host:            "http://localhost:6970",
+			Apikey:          "key",
+			Filters:         []int{4},
+			Emit:            []ArrEmit{ArrEmitTitles, ArrEmitSites},
+			WhisparrVersion: 2,
+		})
+
+		assert.Equal(t, []string{
+			"clients.arr[0].whisparrVersion",
+			"clients.arr[1].whisparrVersion",
+			"clients.arr[2].emit[1]",
+		}, paths(cfg.Validate()))
+	})
+
+	t.Run("filters are optional for set sources", func(t *testing.T) {
+		cfg := validConfig()
+		cfg.Sets = nil
+
+		assert.Equal(t, []string{"lists[0].filters"}, paths(cfg.Validate()))
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `schedule: "0 */6 * * *"
+clients:
+  autobrr:
+    host: http://localhost:7474
+    apikey: ${OMEGABRR_TEST_UNSET}
+lists:
+  - name: trakt
+    type: trakt
+    url: https://api.autobrr.com/lists/trakt/popular-tv
+    filters: [1]
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	_, err := LoadConfig(path)
+
+	var validationErrs ValidationErrors
+	require.True(t, errors.As(err, &validationErrs))
+	assert.Equal(t, []string{"clients.autobrr.apikey", "clients.autobrr.apikey"}, paths(validationErrs))
+	assert.Equal(t, "environment variable OMEGABRR_TEST_UNSET is not set", validationErrs[0].Message)
+}
+
+func TestLoadConfig_env(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `schedule: "0 */6 * * *"
+server:
+  apitoken: from-file
+clients:
+  autobrr:
+    host: http://localhost:7474
+    apikey: from-file
+  arr:
+    - name: radarr
+      type: radarr
+      host: http://localhost:7878
+      apikey: from-file
+      filters: [1]
+lists:
+  - name: trakt
+    type: trakt
+    url: https://api.autobrr.com/lists/trakt/popular-tv
+    filters: [2]
+    headers:
+      X-Api-Key: from-file
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	t.Setenv("OMEGABRR_SERVER_APITOKEN", "from-env")
+	t.Setenv("OMEGABRR_PROCESSING_DEBOUNCE", "1m")
+	t.Setenv("OMEGABRR_CLIENTS_AUTOBRR_APIKEY", "from-env")
+	t.Setenv("OMEGABRR_CLIENTS_ARR_0_APIKEY", "from-env")
+	t.Setenv("OMEGABRR_CLIENTS_ARR_0_FILTERS", "3,4")
+	t.Setenv("OMEGABRR_LISTS_0_HEADERS_X_API_KEY", "from-env")
+	t.Setenv("OMEGABRR_LISTS_0_HEADERS_AUTHORIZATION", "Bearer from-env")
+	t.Setenv("OMEGABRR_UNKNOWN", "ignored")
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "from-env", cfg.Server.APIToken)
+	assert.Equal(t, time.Minute, cfg.Processing.Debounce)
+	assert.Equal(t, "from-env", cfg.Clients.Autobrr.Apikey)
+	assert.Equal(t, "from-env", cfg.Clients.Arr[0].Apikey)
+	assert.Equal(t, []int{3, 4}, cfg.Clients.Arr[0].Filters)
+	assert.Equal(t, "radarr", cfg.Clients.Arr[0].Name)
+	assert.Equal(t, map[string]string{"X-Api-Key": "from-env", "authorization": "Bearer from-env"}, cfg.Lists[0].Headers)
+}
+
+func Test_resolveEnvKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{name: "SERVER_APITOKEN", want: "server.apiToken"},
+		{name: "CLIENTS_AUTOBRR_BASICAUTH_PASS", want: "clients.autobrr.basicAuth.pass"},
+		{name: "CLIENTS_ARR_2_APIKEYFILE", want: "clients.arr.2.apikeyFile"},
+		{name: "LISTS_0_HEADERS_X_API_KEY", want: "lists.0.headers.x-api-key"},
+		{name: "CLIENTS_ARR_APIKEY", wantErr: true},
+		{name: "CLIENTS_ARR", wantErr: true},
+		{name: "SERVER", wantErr: true},
+		{name: "SERVER_APITOKEN_EXTRA", wantErr: true},
+		{name: "MISSING", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveEnvKey(tt.name)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoadConfig_secrets(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "autobrr_apikey"), []byte("from-file\n"), 0600))
+
+	path := filepath.Join(dir, "config.yaml")
+	content := `schedule: "0 */6 * * *"
+clients:
+  autobrr:
+    host: http://localhost:7474
+    apikeyFile: autobrr_apikey
+    basicAuth:
+      user: user
+      passCommand: echo from-command
+  arr:
+    - name: radarr
+      type: radarr
+      host: http://localhost:7878
+      apikey: inline
+      apikeyFile: /run/secrets/radarr
+      filters: [1]
+lists:
+  - name: trakt
+    type: trakt
+    url: https://api.autobrr.com/lists/trakt/popular-tv
+    filters: [2]
+    headerCommands:
+      Authorization: echo Bearer token
+    headerFiles:
+      X-Missing: missing
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	_, err := LoadConfig(path)
+
+	var validationErrs ValidationErrors
+	require.True(t, errors.As(err, &validationErrs))
+	assert.Equal(t, []string{"clients.arr[0].apikey", "lists[0].headerFiles.X-Missing"}, paths(validationErrs))
+
+	cfg := &Config{}
+	cfg.Clients.Autobrr = &AutobrrConfig{ApikeyFile: "autobrr_apikey", BasicAuth: &BasicAuth{PassCommand: "echo from-command"}}
+	cfg.Lists = []*ListConfig{{HeaderCommands: map[string]string{"Authorization": "echo Bearer token"}}}
+
+	assert.Empty(t, cfg.resolveSecrets(dir))
+	assert.Equal(t, "from-file", cfg.Clients.Autobrr.Apikey)
+	assert.Equal(t, "from-command", cfg.Clients.Autobrr.BasicAuth.Pass)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer token"}, cfg.Lists[0].Headers)
+}
+
+func TestLoadConfig_include(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "conf.d"), 0755))
+
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	write("config.yaml", `schedule: "0 */6 * * *"
+include: conf.d/*.yaml
+clients:
+  autobrr:
+    host: http://localhost:7474
+    apikey: key
+  arr:
+    - name: radarr
+      type: radarr
+      host: http://localhost:7878
+      apikey: key
+      filters: [1]
+`)
+	write("conf.d/movies.yaml", `clients:
+  arr:
+    - name: radarr4k
+      type: radarr
+      host: http://localhost:7879
+      apikey: key
+      filters: [2]
+lists:
+  - name: trakt
+    type: trakt
+    url: https://api.autobrr.com/lists/trakt/popular-tv
+    filters: [3]
+`)
+	write("conf.d/music.yaml", `lists:
+  - name: albums
+    type: metacritic
+    url: https://api.autobrr.com/lists/metacritic/new-albums
+    filters: [4]
+`)
+
+	cfg, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+	require.NoError(t, err)
+
+	require.Len(t, cfg.Clients.Arr, 2)
+	assert.Equal(t, "radarr4k", cfg.Clients.Arr[1].Name)
+	require.Len(t, cfg.Lists, 2)
+	assert.Equal(t, "trakt", cfg.Lists[0].Name)
+	assert.Equal(t, "albums", cfg.Lists[1].Name)
+	assert.Equal(t, []string{filepath.Join(dir, "conf.d/*.yaml")}, cfg.Include)
+
+	t.Run("duplicate names", func(t *testing.T) {
+		write("conf.d/team.yaml", `lists:
+  - name: trakt
+    type: trakt
+    url: notaurl
+    filters: [5]
+`)
+
+		_, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+
+		var validationErrs ValidationErrors
+		require.True(t, errors.As(err, &validationErrs))
+		assert.Equal(t, ValidationErrors{
+			{File: filepath.Join("conf.d", "team.yaml"), Path: "lists[0].name", Message: `duplicate name "trakt", already used by conf.d/movies.yaml lists[0].name`},
+			{File: filepath.Join("conf.d", "team.yaml"), Path: "lists[0].url", Message: `must be an http or https URL, got "notaurl"`},
+		}, validationErrs)
+	})
+}
+
+func TestLoadConfig_defaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `schedule: "0 */6 * * *"
+defaults:
+  arr:
+    matchRelease: true
+    includeUnmonitored: true
+    apikeyFile: /run/secrets/missing
+  lists:
+    headers:
+      User-Agent: omegabrr
+clients:
+  autobrr:
+    host: http://localhost:7474
+    apikey: key
+  arr:
+    - name: radarr
+      type: radarr
+      host: http://localhost:7878
+      apikey: key
+      matchRelease: false
+      filters: [1]
+    - name: sonarr
+      type: sonarr
+      host: http://localhost:8989
+      enabled: false
+      filters: [2]
+lists:
+  - name: trakt
+    type: trakt
+    url: https://api.autobrr.com/lists/trakt/popular-tv
+    headers:
+      Authorization: Bearer token
+    filters: [3]
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+
+	radarr := cfg.Clients.Arr[0]
+	assert.False(t, radarr.MatchRelease)
+	assert.True(t, radarr.IncludeUnmonitored)
+	assert.Equal(t, "key", radarr.Apikey)
+	assert.Empty(t, radarr.ApikeyFile)
+	assert.True(t, radarr.IsEnabled())
+
+	sonarr := cfg.Clients.Arr[1]
+	assert.True(t, sonarr.MatchRelease)
+	assert.False(t, sonarr.IsEnabled())
+
+	assert.Equal(t, map[string]string{"User-Agent": "omegabrr", "Authorization": "Bearer token"}, cfg.Lists[0].Headers)
+
+	assert.Equal(t, map[string][]string{"0 */6 * * *": {"radarr", "trakt"}}, cfg.SourceSchedules())
+}
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"github.com/knadh/koanf"
+	"github.com/pkg/errors"
+)
+
+// defaultsKeys are the defaults and the lists of entries inheriting them.
+var defaultsKeys = map[string]string{
+	"defaults.arr":   "clients.arr",
+	"defaults.lists": "lists",
+}
+
+// secretKeys are keys set in one of several ways. An entry setting one of them
+// replaces all of them from the defaults, so an apikey of its own doesn't clash
+// with a default apikeyFile.
+var secretKeys = [][]string{
+	{"apikey", "apikeyFile", "apikeyCommand"},
+	{"basicAuth.pass", "basicAuth.passFile", "basicAuth.passCommand"},
+}
+
+// applyDefaults merges the defaults into every arr and list in k. Keys an entry
+// sets itself win, and maps like headers are merged key by key.
+func applyDefaults(k *koanf.Koanf) error {
+	for defaultsKey, listKey := range defaultsKeys {
+		if !k.Exists(defaultsKey) {
+			continue
+		}
+
+		defaults := k.Cut(defaultsKey)
+
+		items := k.Slices(listKey)
+		if len(items) == 0 {
+			continue
+		}
+
+		raw := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			merged := defaults.Copy()
+			for _, keys := range secretKeys {
+				if setsAny(item, keys) {
+					for _, key := range keys {
+						merged.Delete(key)
+					}
+				}
+			}
+
+			if err := merged.Merge(item); err != nil {
+				return errors.Wrapf(err, "failed applying %s", defaultsKey)
+			}
+			raw = append(raw, merged.Raw())
+		}
+
+		if err := k.Set(listKey, raw); err != nil {
+			return errors.Wrapf(err, "failed applying %s", defaultsKey)
+		}
+	}
+
+	return nil
+}
+
+func setsAny(k *koanf.Koanf, keys []string) bool {
+	for _, key := range keys {
+		if k.Exists(key) {
+			return true
+		}
+	}
+	return false
+}
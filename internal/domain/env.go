@@ -0,0 +1,170 @@
+package domain
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/providers/env"
+	"github.com/pkg/errors"
+)
+
+// EnvPrefix is the prefix of environment variables overriding config keys. The rest
+// of the name is the path of the key, separated by underscores, like
+// OMEGABRR_CLIENTS_AUTOBRR_APIKEY for clients.autobrr.apikey. Arrs and lists are
+// addressed by their index, like OMEGABRR_CLIENTS_ARR_0_APIKEY.
+const EnvPrefix = "OMEGABRR_"
+
+// envIgnored are environment variables with the prefix that are not config keys.
+var envIgnored = map[string]struct{}{
+	"OMEGABRR_CONFIG": {},
+}
+
+// loadEnv overrides the keys loaded in k with the environment variables starting
+// with EnvPrefix. Variables not matching a config key are returned as warnings.
+func loadEnv(k *koanf.Koanf) (warnings []ValidationError, err error) {
+	w := &validator{}
+
+	overrides := koanf.New(".")
+	provider := env.ProviderWithValue(EnvPrefix, ".", func(name, value string) (string, interface{}) {
+		if _, ok := envIgnored[name]; ok {
+			return "", nil
+		}
+
+		key, err := resolveEnvKey(strings.TrimPrefix(name, EnvPrefix))
+		if err != nil {
+			w.check(true, name, "%v, it is ignored", err)
+			return "", nil
+		}
+
+		return key, value
+	})
+
+	if err := overrides.Load(provider, nil); err != nil {
+		return nil, errors.Wrap(err, "failed loading environment variables")
+	}
+
+	all := overrides.All()
+	keys := make([]string, 0, len(all))
+	for key := range all {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := setKey(k, key, all[key]); err != nil {
+			return nil, errors.Wrapf(err, "failed overriding %s", key)
+		}
+	}
+
+	return w.errs, nil
+}
+
+// resolveEnvKey returns the config key an environment variable name without the
+// prefix refers to, like clients.arr.0.apikey for CLIENTS_ARR_0_APIKEY. Keys are
+// matched case-insensitively, and the rest of the name after headers is the header
+// name, with underscores for dashes.
+func resolveEnvKey(name string) (string, error) {
+	segments := strings.Split(strings.ToLower(name), "_")
+
+	t := reflect.TypeOf(Config{})
+	path := make([]string, 0, len(segments))
+
+	for i := 0; i < len(segments); i++ {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := fieldByKey(t, segments[i])
+			if !ok {
+				return "", errors.Errorf("unknown config key %s", strings.Join(append(path, segments[i]), "."))
+			}
+			path = append(path, koanfKey(field))
+			t = field.Type
+
+		case reflect.Slice:
+			if _, err := strconv.Atoi(segments[i]); err != nil {
+				return "", errors.Errorf("%s needs an index, like %s_0", strings.Join(path, "."), strings.ToUpper(strings.Join(segments[:i], "_")))
+			}
+			path = append(path, segments[i])
+			t = t.Elem()
+
+		case reflect.Map:
+			return strings.Join(append(path, strings.Join(segments[i:], "-")), "."), nil
+
+		default:
+			return "", errors.Errorf("unknown config key %s", strings.Join(append(path, segments[i:]...), "."))
+		}
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct || t.Kind() == reflect.Map || (t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Int && t.Elem().Kind() != reflect.String) {
+		return "", errors.Errorf("%s is not a single value", strings.Join(path, "."))
+	}
+
+	return strings.Join(path, "."), nil
+}
+
+func fieldByKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if k := koanfKey(t.Field(i)); k != "" && strings.EqualFold(k, key) {
+			return t.Field(i), true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// setKey sets a key in k, going into lists for keys with an index. A key already
+// in the config with a different case is overridden, so header names keep their case.
+func setKey(k *koanf.Koanf, key string, value interface{}) error {
+	segments := strings.Split(key, ".")
+
+	for i, segment := range segments {
+		index, err := strconv.Atoi(segment)
+		if err != nil {
+			continue
+		}
+
+		listPath := strings.Join(segments[:i], ".")
+		items := k.Slices(listPath)
+		if index >= len(items) {
+			return errors.Errorf("%s has %d items", listPath, len(items))
+		}
+
+		if err := setKey(items[index], strings.Join(segments[i+1:], "."), value); err != nil {
+			return err
+		}
+
+		raw := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			raw = append(raw, item.Raw())
+		}
+
+		return k.Set(listPath, raw)
+	}
+
+	parent, last := "", key
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		parent, last = key[:i]+".", key[i+1:]
+	}
+
+	existing := k.Raw()
+	if parent != "" {
+		existing, _ = k.Get(strings.TrimSuffix(parent, ".")).(map[string]interface{})
+	}
+	for name := range existing {
+		if strings.EqualFold(name, last) {
+			key = parent + name
+			break
+		}
+	}
+
+	return k.Set(key, value)
+}
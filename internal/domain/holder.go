@@ -0,0 +1,23 @@
+package domain
+
+import "sync/atomic"
+
+// ConfigHolder shares the current config between services, so a reloaded config
+// can be swapped in for all of them at once.
+type ConfigHolder struct {
+	cfg atomic.Pointer[Config]
+}
+
+func NewConfigHolder(cfg *Config) *ConfigHolder {
+	h := &ConfigHolder{}
+	h.cfg.Store(cfg)
+	return h
+}
+
+func (h *ConfigHolder) Get() *Config {
+	return h.cfg.Load()
+}
+
+func (h *ConfigHolder) Set(cfg *Config) {
+	h.cfg.Store(cfg)
+}
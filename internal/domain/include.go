@@ -0,0 +1,226 @@
+package domain
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/rawbytes"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// includeFile is what an included file can contain. It has its own version, and
+// is migrated like the config file, since it has a part of the same layout.
+type includeFile struct {
+	Version int `koanf:"version"`
+	Clients struct {
+		Arr []*ArrConfig `koanf:"arr"`
+	} `koanf:"clients"`
+	Lists []*ListConfig `koanf:"lists"`
+	Sets  []*SetConfig  `koanf:"sets"`
+}
+
+// includeKeys are the lists merged from included files, in the order they are merged.
+var includeKeys = []string{"clients.arr", "lists", "sets"}
+
+// includedEntry is where an arr, list or set from an included file came from.
+type includedEntry struct {
+	file string
+	path string
+}
+
+// loadIncludes appends the arrs, lists and sets of the files matching the include
+// patterns in k to those in k. Patterns are relative to dir, the directory of the
+// config file, and files are merged in the order they match. It returns where each
+// included entry came from, keyed by its path in the merged config, like lists[3].
+func loadIncludes(k *koanf.Koanf, dir string) (map[string]includedEntry, ValidationErrors, error) {
+	includes := make(map[string]includedEntry)
+
+	files, errs := includeFiles(k, dir)
+	for _, file := range files {
+		fileErrs, err := loadInclude(k, file, displayPath(file, dir), includes)
+		if err != nil {
+			return nil, nil, err
+		}
+		errs = append(errs, fileErrs...)
+	}
+
+	return includes, errs, nil
+}
+
+// includeFiles returns the files matching the include patterns in k, once each and
+// in the order they are merged. Patterns are relative to dir.
+func includeFiles(k *koanf.Koanf, dir string) ([]string, ValidationErrors) {
+	patterns := k.Strings("include")
+	if pattern, ok := k.Get("include").(string); ok {
+		patterns = []string{pattern}
+	}
+
+	v := &validator{}
+	seen := make(map[string]struct{})
+	var files []string
+
+	for i, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			v.check(true, fmt.Sprintf("include[%d]", i), "invalid pattern %q: %v", patterns[i], err)
+			continue
+		}
+
+		for _, file := range matches {
+			if _, ok := seen[file]; ok {
+				continue
+			}
+			seen[file] = struct{}{}
+			files = append(files, file)
+		}
+	}
+
+	return files, v.errs
+}
+
+// IncludedFiles returns the files included by the config file, for config migrate.
+func IncludedFiles(configPath string) ([]string, error) {
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading %q", configPath)
+	}
+
+	k := koanf.New(".")
+	if err := k.Load(rawbytes.Provider([]byte(os.ExpandEnv(string(content)))), yaml.Parser()); err != nil {
+		return nil, errors.Wrapf(err, "failed parsing %q", configPath)
+	}
+
+	files, errs := includeFiles(k, filepath.Dir(configPath))
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return files, nil
+}
+
+func loadInclude(k *koanf.Koanf, file, name string, includes map[string]includedEntry) (ValidationErrors, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading included %q", file)
+	}
+
+	// included files are upgraded like the config file, so they are merged in the current layout
+	content, version, warnings, err := MigrateConfig(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed migrating included %q", file)
+	}
+	for _, w := range warnings {
+		log.Warn().Str("service", "config").Msgf("%s: %s", name, w)
+	}
+	if version < ConfigVersion {
+		log.Warn().Str("service", "config").Msgf("%s: included file is at version %d, run omegabrr config migrate to upgrade it to version %d", name, version, ConfigVersion)
+	}
+
+	for _, w := range unknownKeys(content, reflect.TypeOf(includeFile{})) {
+		w.File = name
+		log.Warn().Str("service", "config").Msg(w.Error())
+	}
+
+	v := &validator{errs: validateEnv(content)}
+	v.check(version > ConfigVersion, "version", "version %d is newer than this omegabrr supports (%d), upgrade omegabrr", version, ConfigVersion)
+
+	errs := v.errs
+	for i := range errs {
+		errs[i].File = name
+	}
+
+	included := koanf.New(".")
+	if err := included.Load(rawbytes.Provider([]byte(os.ExpandEnv(string(content)))), yaml.Parser()); err != nil {
+		return nil, errors.Wrapf(err, "failed parsing included %q", file)
+	}
+
+	for _, key := range includeKeys {
+		items := included.Slices(key)
+		if len(items) == 0 {
+			continue
+		}
+
+		existing := k.Slices(key)
+		raw := make([]interface{}, 0, len(existing)+len(items))
+		for _, item := range existing {
+			raw = append(raw, item.Raw())
+		}
+
+		for i, item := range items {
+			includes[fmt.Sprintf("%s[%d]", key, len(raw))] = includedEntry{
+				file: name,
+				path: fmt.Sprintf("%s[%d]", key, i),
+			}
+			raw = append(raw, item.Raw())
+		}
+
+		if err := k.Set(key, raw); err != nil {
+			return nil, errors.Wrapf(err, "failed merging %s of included %q", key, file)
+		}
+	}
+
+	return errs, nil
+}
+
+// displayPath returns file relative to dir if it is inside it, to keep errors short.
+func displayPath(file, dir string) string {
+	rel, err := filepath.Rel(dir, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return file
+	}
+
+	return rel
+}
+
+// locate points errors about included arrs, lists and sets at the file and path
+// they came from.
+func (c *Config) locate(errs ValidationErrors) ValidationErrors {
+	for i, err := range errs {
+		if err.File != "" {
+			continue
+		}
+		errs[i].File, errs[i].Path = c.origin(err.Path)
+	}
+
+	return errs
+}
+
+// origin returns the file and path in that file of a path in the merged config.
+// The file is empty for paths in the config file itself.
+func (c *Config) origin(path string) (file, originPath string) {
+	end := strings.Index(path, "]")
+	if end < 0 {
+		return "", path
+	}
+
+	entry, ok := c.includes[path[:end+1]]
+	if !ok {
+		return "", path
+	}
+
+	return entry.file, entry.path + path[end+1:]
+}
+
+// describe returns a path in the merged config as the file and path it came from.
+func (c *Config) describe(path string) string {
+	file, originPath := c.origin(path)
+	if file == "" {
+		if len(c.includes) == 0 || c.file == "" {
+			return originPath
+		}
+		// name the config file too, once entries come from several files
+		file = filepath.Base(c.file)
+	}
+
+	return fmt.Sprintf("%s %s", file, originPath)
+}
@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// migration upgrades a config from the version before it to its version. It
+// changes the YAML document in place, and returns a warning for every change a
+// user should make to their config.
+type migration struct {
+	version int
+	migrate func(root *yaml.Node) []string
+}
+
+// migrations are applied in order to configs with an older version. A config
+// without a version key is version 0, the layout before versions were added.
+var migrations = []migration{
+	// 1 only adds the version key
+	{
+		version: 1,
+		migrate: func(root *yaml.Node) []string { return nil },
+	},
+}
+
+// ConfigVersion is the version of the config layout this omegabrr reads, and the
+// version new config files are created with.
+var ConfigVersion = migrations[len(migrations)-1].version
+
+// MigrateConfig upgrades the content of a config file to ConfigVersion. It returns
+// the content unchanged when it is already at that version, otherwise the migrated
+// content with the version key set, and warnings about what was changed. Comments
+// are kept, but blank lines, the --- marker and the indentation may change.
+func MigrateConfig(content []byte) (migrated []byte, from int, warnings []string, err error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, 0, nil, errors.Wrap(err, "failed parsing config")
+	}
+
+	// an empty file has no document
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, 0, nil, errors.New("config must be a map of keys")
+	}
+
+	from, err = configVersion(root)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	// a newer version is reported by Validate
+	if from >= ConfigVersion {
+		return content, from, nil, nil
+	}
+
+	for _, m := range migrations {
+		if m.version <= from {
+			continue
+		}
+		for _, w := range m.migrate(root) {
+			warnings = append(warnings, fmt.Sprintf("version %d: %s", m.version, w))
+		}
+	}
+
+	setVersion(root, ConfigVersion)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, from, nil, errors.Wrap(err, "failed writing migrated config")
+	}
+	if err := enc.Close(); err != nil {
+		return nil, from, nil, errors.Wrap(err, "failed writing migrated config")
+	}
+
+	return buf.Bytes(), from, warnings, nil
+}
+
+// configVersion returns the version key of a config, or 0 if it has none.
+func configVersion(root *yaml.Node) (int, error) {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "version" {
+			continue
+		}
+
+		version, err := strconv.Atoi(root.Content[i+1].Value)
+		if err != nil || version < 0 {
+			return 0, errors.Errorf("version must be a number, got %q", root.Content[i+1].Value)
+		}
+		return version, nil
+	}
+
+	return 0, nil
+}
+
+// setVersion sets the version key, adding it as the first key if it is missing.
+func setVersion(root *yaml.Node, version int) {
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(version)}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "version" {
+			root.Content[i+1] = value
+			return
+		}
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}
+	if len(root.Content) > 0 {
+		// keep comments at the top of the file above the version
+		key.HeadComment = root.Content[0].HeadComment
+		root.Content[0].HeadComment = ""
+	}
+	root.Content = append([]*yaml.Node{key, value}, root.Content...)
+}
@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	t.Run("adds the version to configs without one", func(t *testing.T) {
+		content := `# config.yaml
+---
+schedule: "0 */6 * * *" # every 6 hours
+lists:
+  - name: trakt
+    type: trakt
+    #filters: [3]
+`
+
+		migrated, from, warnings, err := MigrateConfig([]byte(content))
+		require.NoError(t, err)
+		assert.Equal(t, 0, from)
+		assert.Empty(t, warnings)
+		assert.Equal(t, `# config.yaml
+version: 1
+schedule: "0 */6 * * *" # every 6 hours
+lists:
+  - name: trakt
+    type: trakt
+    #filters: [3]
+`, string(migrated))
+	})
+
+	t.Run("keeps configs at the current version as they are", func(t *testing.T) {
+		content := "version: 1\nschedule:   \"0 */6 * * *\"\n"
+
+		migrated, from, _, err := MigrateConfig([]byte(content))
+		require.NoError(t, err)
+		assert.Equal(t, ConfigVersion, from)
+		assert.Equal(t, content, string(migrated))
+	})
+
+	t.Run("invalid version", func(t *testing.T) {
+		_, _, _, err := MigrateConfig([]byte("version: one\n"))
+		assert.EqualError(t, err, `version must be a number, got "one"`)
+	})
+}
+
+func TestLoadConfig_version(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `version: 99
+clients:
+  autobrr:
+    host: http://localhost:7474
+    apikey: key
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	_, err := LoadConfig(path)
+
+	var errs ValidationErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, []string{"version"}, paths(errs))
+}
+
+func TestLoadConfig_includeVersion(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(`version: 1
+include: [old.yaml, new.yaml]
+clients:
+  autobrr:
+    host: http://localhost:7474
+    apikey: key
+`), 0o644))
+	// an include without a version is migrated like the config file
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.yaml"), []byte(`lists:
+  - name: trakt
+    type: trakt
+    url: https://api.autobrr.com/lists/trakt/popular-tv
+    filters: [1]
+`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.yaml"), []byte("version: 99\n"), 0o644))
+
+	_, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+
+	var errs ValidationErrors
+	require.ErrorAs(t, err, &errs)
+	assert.Equal(t, ValidationErrors{
+		{File: "new.yaml", Path: "version", Message: fmt.Sprintf("version 99 is newer than this omegabrr supports (%d), upgrade omegabrr", ConfigVersion)},
+	}, errs)
+}
+
+func TestIncludedFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "conf.d"), 0o755))
+	for _, name := range []string{"config.yaml", "conf.d/b.yaml", "conf.d/a.yaml", "extra.yaml"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("include: [conf.d/*.yaml, extra.yaml, conf.d/a.yaml]\n"), 0o644))
+	}
+
+	files, err := IncludedFiles(filepath.Join(dir, "config.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "conf.d", "a.yaml"),
+		filepath.Join(dir, "conf.d", "b.yaml"),
+		filepath.Join(dir, "extra.yaml"),
+	}, files)
+}
+
+func TestConfig_writeFile_version(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, (&Config{}).writeFile(path))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	_, from, _, err := MigrateConfig(content)
+	require.NoError(t, err)
+	assert.Equal(t, ConfigVersion, from)
+}
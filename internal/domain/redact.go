@@ -0,0 +1,202 @@
+package domain
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Redacted replaces secrets in the config.
+const Redacted = "REDACTED"
+
+// secretParams are parts of URL query parameter names that hold secrets, like apikey.
+var secretParams = []string{"key", "token", "secret", "pass", "auth"}
+
+// Redacted returns a copy of the config with apikeys, passwords, tokens, list
+// headers, the commands secrets are read from and secrets in URLs replaced.
+// Empty values are kept, so it still shows what is not set.
+func (c *Config) Redacted() *Config {
+	r := *c
+
+	r.Server.APIToken = redact(c.Server.APIToken)
+
+	if a := c.Clients.Autobrr; a != nil {
+		autobrr := *a
+		autobrr.Host = redactURL(a.Host)
+		autobrr.Apikey = redact(a.Apikey)
+		autobrr.ApikeyCommand = redact(a.ApikeyCommand)
+		autobrr.BasicAuth = redactBasicAuth(a.BasicAuth)
+		r.Clients.Autobrr = &autobrr
+	}
+
+	r.Clients.Arr = nil
+	for _, a := range c.Clients.Arr {
+		r.Clients.Arr = append(r.Clients.Arr, redactArr(a))
+	}
+
+	r.Lists = nil
+	for _, l := range c.Lists {
+		r.Lists = append(r.Lists, redactList(l))
+	}
+
+	r.Defaults.Arr = redactArr(c.Defaults.Arr)
+	r.Defaults.Lists = redactList(c.Defaults.Lists)
+
+	return &r
+}
+
+func redactArr(a *ArrConfig) *ArrConfig {
+	if a == nil {
+		return nil
+	}
+
+	arr := *a
+	arr.Host = redactURL(a.Host)
+	arr.Apikey = redact(a.Apikey)
+	arr.ApikeyCommand = redact(a.ApikeyCommand)
+	arr.BasicAuth = redactBasicAuth(a.BasicAuth)
+
+	return &arr
+}
+
+func redactList(l *ListConfig) *ListConfig {
+	if l == nil {
+		return nil
+	}
+
+	list := *l
+	list.URL = redactURL(l.URL)
+	list.BasicAuth = redactBasicAuth(l.BasicAuth)
+
+	list.Headers = redactValues(l.Headers)
+	list.HeaderCommands = redactValues(l.HeaderCommands)
+
+	return &list
+}
+
+// redactValues replaces every value of a map, since any header can hold a token.
+func redactValues(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+
+	redacted := make(map[string]string, len(m))
+	for name, value := range m {
+		redacted[name] = redact(value)
+	}
+
+	return redacted
+}
+
+func redactBasicAuth(b *BasicAuth) *BasicAuth {
+	if b == nil {
+		return nil
+	}
+
+	auth := *b
+	auth.Pass = redact(b.Pass)
+	auth.PassCommand = redact(b.PassCommand)
+
+	return &auth
+}
+
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	return Redacted
+}
+
+// redactURL replaces the password and secret query parameters of a URL.
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return value
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), Redacted)
+	}
+
+	query := u.Query()
+	for name := range query {
+		lower := strings.ToLower(name)
+		for _, secret := range secretParams {
+			if strings.Contains(lower, secret) {
+				query.Set(name, Redacted)
+				break
+			}
+		}
+	}
+	if len(query) > 0 {
+		u.RawQuery = query.Encode()
+	}
+
+	return u.String()
+}
+
+// Map returns the config as nested maps keyed like config.yaml, for printing it.
+// Durations are written like 30s, and unset strings, pointers, lists and maps are
+// left out.
+func (c *Config) Map() map[string]interface{} {
+	m, _ := configValue(reflect.ValueOf(c)).(map[string]interface{})
+	return m
+}
+
+func configValue(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		return time.Duration(v.Int()).String()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		m := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			key := koanfKey(v.Type().Field(i))
+			if key == "" {
+				continue
+			}
+			if value := configValue(v.Field(i)); value != nil {
+				m[key] = value
+			}
+		}
+		if len(m) == 0 {
+			return nil
+		}
+		return m
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		s := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s = append(s, configValue(v.Index(i)))
+		}
+		return s
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = configValue(iter.Value())
+		}
+		return m
+	case reflect.String:
+		if v.String() == "" {
+			return nil
+		}
+		return v.String()
+	default:
+		return v.Interface()
+	}
+}
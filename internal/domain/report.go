@@ -0,0 +1,174 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+type RunTrigger string
+
+var (
+	RunTriggerSchedule RunTrigger = "schedule"
+	RunTriggerStartup  RunTrigger = "startup"
+	RunTriggerWebhook  RunTrigger = "webhook"
+	RunTriggerCLI      RunTrigger = "cli"
+)
+
+type RunStatus string
+
+var (
+	RunStatusQueued    RunStatus = "queued"
+	RunStatusRunning   RunStatus = "running"
+	RunStatusSuccess   RunStatus = "success"
+	RunStatusPartial   RunStatus = "partial"
+	RunStatusFailed    RunStatus = "failed"
+	RunStatusCancelled RunStatus = "cancelled"
+)
+
+type SourceStatus string
+
+var (
+	SourceStatusRunning SourceStatus = "running"
+	SourceStatusOK      SourceStatus = "ok"
+	SourceStatusFailed  SourceStatus = "failed"
+	SourceStatusSkipped SourceStatus = "skipped"
+)
+
+type FilterStatus string
+
+var (
+	FilterStatusUpdated   FilterStatus = "updated"
+	FilterStatusUnchanged FilterStatus = "unchanged"
+	FilterStatusEmpty     FilterStatus = "empty"
+	FilterStatusDryRun    FilterStatus = "dry-run"
+	FilterStatusSkipped   FilterStatus = "skipped"
+	FilterStatusFailed    FilterStatus = "failed"
+)
+
+// ErrorClass groups run errors by what went wrong.
+type ErrorClass string
+
+var (
+	ErrorClassConfig    ErrorClass = "config"
+	ErrorClassFetch     ErrorClass = "fetch"
+	ErrorClassTimeout   ErrorClass = "timeout"
+	ErrorClassCancelled ErrorClass = "cancelled"
+	ErrorClassUpdate    ErrorClass = "update"
+)
+
+// RunError is an error that happened while processing a source or filter.
+type RunError struct {
+	Class   ErrorClass `json:"class"`
+	Message string     `json:"message"`
+}
+
+func (e *RunError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Class, e.Message)
+}
+
+// RunReport records a single processing run, from trigger to filter updates.
+type RunReport struct {
+	ID         string         `json:"id"`
+	Trigger    RunTrigger     `json:"trigger"`
+	Status     RunStatus      `json:"status"`
+	DryRun     bool           `json:"dryRun"`
+	StartedAt  time.Time      `json:"startedAt"`
+	FinishedAt time.Time      `json:"finishedAt"`
+	Duration   time.Duration  `json:"duration"`
+	Sources    []SourceReport `json:"sources"`
+	Filters    []FilterReport `json:"filters"`
+
+	// MergedTriggers are the triggers of other requests that were merged into the
+	// run, besides Trigger.
+	MergedTriggers []RunTrigger `json:"mergedTriggers,omitempty"`
+}
+
+// SourceReport is the outcome of fetching a single arr, list or set.
+type SourceReport struct {
+	Name            string       `json:"name"`
+	Type            string       `json:"type"`
+	Kind            string       `json:"kind"`
+	Status          SourceStatus `json:"status"`
+	ItemsFetched    int          `json:"itemsFetched"`
+	TitlesGenerated int          `json:"titlesGenerated"`
+	Error           *RunError    `json:"error,omitempty"`
+}
+
+// FilterReport is the outcome of updating a single filter, with the titles that
+// were added or removed compared to what was last written to it.
+type FilterReport struct {
+	ID      int          `json:"id"`
+	Sources []string     `json:"sources"`
+	Status  FilterStatus `json:"status"`
+	Titles  int          `json:"titles"`
+	Added   []string     `json:"added,omitempty"`
+	Removed []string     `json:"removed,omitempty"`
+	Error   *RunError    `json:"error,omitempty"`
+}
+
+// FilterState is what omegabrr last wrote to a filter.
+type FilterState struct {
+	ID          int       `json:"id"`
+	Titles      []string  `json:"titles"`
+	Fingerprint string    `json:"fingerprint"`
+	RunID       string    `json:"runId"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// Errors returns a line for every source and filter that failed.
+func (r *RunReport) Errors() []string {
+	var errs []string
+
+	for _, src := range r.Sources {
+		if src.Error != nil {
+			errs = append(errs, fmt.Sprintf("%s - %s: %v", src.Type, src.Name, src.Error))
+		}
+	}
+
+	for _, f := range r.Filters {
+		if f.Error != nil {
+			errs = append(errs, fmt.Sprintf("filter %d: %v", f.ID, f.Error))
+		}
+	}
+
+	return errs
+}
+
+// Result tells whether the run went fine, was cancelled, failed in part, or failed
+// completely, which is when there were errors and no filter was brought up to date.
+func (r *RunReport) Result() RunStatus {
+	if len(r.Errors()) == 0 {
+		return RunStatusSuccess
+	}
+
+	if _, ok := r.ErrorClasses()[ErrorClassCancelled]; ok {
+		return RunStatusCancelled
+	}
+
+	for _, f := range r.Filters {
+		if f.Error == nil {
+			return RunStatusPartial
+		}
+	}
+
+	return RunStatusFailed
+}
+
+// ErrorClasses returns the distinct classes of all errors in the run.
+func (r *RunReport) ErrorClasses() map[ErrorClass]struct{} {
+	classes := make(map[ErrorClass]struct{})
+
+	for _, src := range r.Sources {
+		if src.Error != nil {
+			classes[src.Error.Class] = struct{}{}
+		}
+	}
+
+	for _, f := range r.Filters {
+		if f.Error != nil {
+			classes[f.Error.Class] = struct{}{}
+		}
+	}
+
+	return classes
+}
@@ -0,0 +1,281 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaURL is where the JSON Schema of config.yaml is published, for editors
+// with a YAML language server.
+const SchemaURL = "https://raw.githubusercontent.com/autobrr/omegabrr/main/config.schema.json"
+
+// durationPattern matches the durations time.ParseDuration accepts, like 30s or 1h30m.
+const durationPattern = `^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+
+// JSONSchema is the subset of JSON Schema used to describe config.yaml.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	ID                   string                 `json:"$id,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	AnyOf                []*JSONSchema          `json:"anyOf,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+}
+
+// schemaEnums are the allowed values of the string types with a fixed set of values.
+var schemaEnums = map[reflect.Type][]string{
+	reflect.TypeOf(ArrType("")):      enumValues(ArrTypes),
+	reflect.TypeOf(ListType("")):     enumValues(ListTypes),
+	reflect.TypeOf(SetOperation("")): enumValues(SetOperations),
+	reflect.TypeOf(ArrEmit("")):      arrEmitValues(),
+	reflect.TypeOf(AlbumType("")):    enumValues(AlbumTypes),
+	reflect.TypeOf(CreditMode("")):   enumValues(CreditModes),
+}
+
+// schemaRequired are the keys Validate requires, so editors flag them as missing.
+// The apikey is not listed, since it can be set with apikeyFile or apikeyCommand too.
+var schemaRequired = map[reflect.Type][]string{
+	reflect.TypeOf(AutobrrConfig{}): {"host"},
+	reflect.TypeOf(ArrConfig{}):     {"name", "type", "host"},
+	reflect.TypeOf(ListConfig{}):    {"name", "type", "url"},
+	reflect.TypeOf(SetConfig{}):     {"name", "operation", "sources", "filters"},
+}
+
+// arrEmitValues are the values emit has for any arr type.
+func arrEmitValues() []string {
+	var values []string
+	seen := make(map[ArrEmit]struct{})
+	for _, arrType := range ArrTypes {
+		for _, emit := range ArrEmits[arrType] {
+			if _, ok := seen[emit]; !ok {
+				seen[emit] = struct{}{}
+				values = append(values, string(emit))
+			}
+		}
+	}
+
+	return values
+}
+
+func enumValues[T ~string](values []T) []string {
+	s := make([]string, 0, len(values))
+	for _, v := range values {
+		s = append(s, string(v))
+	}
+	return s
+}
+
+// Schema returns the JSON Schema of config.yaml, generated from the koanf tags of Config.
+func Schema() *JSONSchema {
+	s := schemaFor(reflect.TypeOf(Config{}))
+	s.Schema = "http://json-schema.org/draft-07/schema#"
+	s.ID = SchemaURL
+	s.Title = "omegabrr config"
+
+	// defaults only fill in what entries don't set, so nothing is required there
+	for _, d := range s.Properties["defaults"].Properties {
+		d.Required = nil
+	}
+
+	// a single pattern can be set without a list
+	s.Properties["include"] = &JSONSchema{AnyOf: []*JSONSchema{
+		{Type: "string"},
+		{Type: "array", Items: &JSONSchema{Type: "string"}},
+	}}
+
+	return s
+}
+
+// SchemaJSON returns the indented JSON Schema of config.yaml.
+func SchemaJSON() ([]byte, error) {
+	b, err := json.MarshalIndent(Schema(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+func schemaFor(t reflect.Type) *JSONSchema {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if enum, ok := schemaEnums[t]; ok {
+		return &JSONSchema{Type: "string", Enum: enum}
+	}
+
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return &JSONSchema{Type: "string", Pattern: durationPattern}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.Slice:
+		return &JSONSchema{Type: "array", Items: schemaFor(t.Elem())}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: schemaFor(t.Elem())}
+	case reflect.Struct:
+		s := &JSONSchema{
+			Type:                 "object",
+			Properties:           map[string]*JSONSchema{},
+			Required:             schemaRequired[t],
+			AdditionalProperties: false,
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if key := koanfKey(t.Field(i)); key != "" {
+				s.Properties[key] = schemaFor(t.Field(i).Type)
+			}
+		}
+		return s
+	default:
+		return &JSONSchema{}
+	}
+}
+
+func koanfKey(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+
+	key, _, _ := strings.Cut(f.Tag.Get("koanf"), ",")
+	if key == "-" {
+		return ""
+	}
+
+	return key
+}
+
+// UnknownKeys returns a warning for every key in the raw config that is not part
+// of Config, since koanf drops them silently when unmarshalling.
+func UnknownKeys(content []byte) []ValidationError {
+	return unknownKeys(content, reflect.TypeOf(Config{}))
+}
+
+func unknownKeys(content []byte, t reflect.Type) []ValidationError {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		// reported when the config is parsed
+		return nil
+	}
+
+	v := &validator{}
+	walkKeys(&root, t, "", v)
+
+	return v.errs
+}
+
+func walkKeys(node *yaml.Node, t reflect.Type, path string, v *validator) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			walkKeys(n, t, path, v)
+		}
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice {
+			return
+		}
+		for i, n := range node.Content {
+			walkKeys(n, t.Elem(), fmt.Sprintf("%s[%d]", path, i), v)
+		}
+	case yaml.MappingNode:
+		if t.Kind() != reflect.Struct {
+			return
+		}
+
+		fields := make(map[string]reflect.Type, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			if key := koanfKey(t.Field(i)); key != "" {
+				fields[key] = t.Field(i).Type
+			}
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+
+			field, ok := fields[key]
+			if !ok {
+				msg := "unknown key, it is ignored"
+				if suggestion := closestKey(key, fields); suggestion != "" {
+					msg = fmt.Sprintf("unknown key, it is ignored (did you mean %q?)", suggestion)
+				}
+				v.check(true, keyPath, "%s", msg)
+				continue
+			}
+
+			walkKeys(node.Content[i+1], field, keyPath, v)
+		}
+	}
+}
+
+// closestKey returns the known key a typo was most likely meant to be, if any.
+func closestKey(key string, fields map[string]reflect.Type) string {
+	known := make([]string, 0, len(fields))
+	for k := range fields {
+		known = append(known, k)
+	}
+	sort.Strings(known)
+
+	best, bestDistance := "", 3
+	for _, k := range known {
+		if strings.EqualFold(k, key) {
+			return k
+		}
+		if d := levenshtein(strings.ToLower(k), strings.ToLower(key)); d < bestDistance {
+			best, bestDistance = k, d
+		}
+	}
+
+	return best
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	s := Schema()
+
+	arr := s.Properties["clients"].Properties["arr"].Items
+	assert.Equal(t, enumValues(ArrTypes), arr.Properties["type"].Enum)
+	assert.Equal(t, "integer", arr.Properties["filters"].Items.Type)
+	assert.Equal(t, []string{"name", "type", "host"}, arr.Required)
+	assert.Equal(t, false, arr.AdditionalProperties)
+
+	list := s.Properties["lists"].Items
+	assert.Equal(t, enumValues(ListTypes), list.Properties["type"].Enum)
+	assert.Equal(t, "string", list.Properties["headers"].AdditionalProperties.(*JSONSchema).Type)
+
+	assert.Equal(t, durationPattern, s.Properties["processing"].Properties["sourceTimeout"].Pattern)
+}
+
+// The published schema is what editors use, so it must match the config.
+func TestSchema_published(t *testing.T) {
+	published, err := os.ReadFile("../../config.schema.json")
+	require.NoError(t, err)
+
+	generated, err := SchemaJSON()
+	require.NoError(t, err)
+
+	assert.True(t, string(generated) == string(published), "config.schema.json is out of date, run omegabrr config schema > config.schema.json")
+}
+
+func TestUnknownKeys(t *testing.T) {
+	content := `schedule: "0 */6 * * *"
+schedular:
+  runOnStart: false
+clients:
+  arr:
+    - name: radarr
+      matchRelase: true
+      tagsinclude: [a]
+      headers: {}
+lists:
+  - name: trakt
+    headers:
+      X-Custom: value
+`
+
+	assert.Equal(t, []ValidationError{
+		{Path: "schedular", Message: `unknown key, it is ignored (did you mean "scheduler"?)`},
+		{Path: "clients.arr[0].matchRelase", Message: `unknown key, it is ignored (did you mean "matchRelease"?)`},
+		{Path: "clients.arr[0].tagsinclude", Message: `unknown key, it is ignored (did you mean "tagsInclude"?)`},
+		{Path: "clients.arr[0].headers", Message: "unknown key, it is ignored"},
+	}, UnknownKeys([]byte(content)))
+}
@@ -0,0 +1,189 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// secretCommandTimeout bounds how long a command printing a secret may take.
+const secretCommandTimeout = 30 * time.Second
+
+// resolveSecrets fills in the apikeys, passwords and headers set with a file or a
+// command. Relative file paths and commands are relative to dir, the directory of
+// the config file.
+func (c *Config) resolveSecrets(dir string) ValidationErrors {
+	v := &validator{}
+
+	if a := c.Clients.Autobrr; a != nil {
+		v.secret(&a.Apikey, a.ApikeyFile, a.ApikeyCommand, "clients.autobrr", "apikey", dir)
+		v.basicAuth(a.BasicAuth, "clients.autobrr", dir)
+	}
+
+	// disabled arrs and lists are not fetched, so their secrets aren't needed
+	for i, arr := range c.Clients.Arr {
+		if !arr.IsEnabled() {
+			continue
+		}
+		path := fmt.Sprintf("clients.arr[%d]", i)
+
+		v.secret(&arr.Apikey, arr.ApikeyFile, arr.ApikeyCommand, path, "apikey", dir)
+		v.basicAuth(arr.BasicAuth, path, dir)
+	}
+
+	for i, list := range c.Lists {
+		if !list.IsEnabled() {
+			continue
+		}
+		path := fmt.Sprintf("lists[%d]", i)
+
+		v.basicAuth(list.BasicAuth, path, dir)
+		v.headers(list, path, dir)
+	}
+
+	return c.locate(v.errs)
+}
+
+func (v *validator) basicAuth(auth *BasicAuth, path, dir string) {
+	if auth == nil {
+		return
+	}
+
+	v.secret(&auth.Pass, auth.PassFile, auth.PassCommand, path+".basicAuth", "pass", dir)
+}
+
+// headers adds the headers set with a file or a command to the list headers.
+func (v *validator) headers(list *ListConfig, path, dir string) {
+	if len(list.HeaderFiles) == 0 && len(list.HeaderCommands) == 0 {
+		return
+	}
+
+	if list.Headers == nil {
+		list.Headers = make(map[string]string)
+	}
+
+	set := func(name, value, key string) {
+		for existing := range list.Headers {
+			if strings.EqualFold(existing, name) {
+				v.check(true, fmt.Sprintf("%s.%s.%s", path, key, name), "header is already set in headers")
+				return
+			}
+		}
+		list.Headers[name] = value
+	}
+
+	for _, name := range sortedKeys(list.HeaderFiles) {
+		value, err := readSecretFile(list.HeaderFiles[name], dir)
+		if err != nil {
+			v.check(true, fmt.Sprintf("%s.headerFiles.%s", path, name), "%v", err)
+			continue
+		}
+		set(name, value, "headerFiles")
+	}
+
+	for _, name := range sortedKeys(list.HeaderCommands) {
+		value, err := runSecretCommand(list.HeaderCommands[name], dir)
+		if err != nil {
+			v.check(true, fmt.Sprintf("%s.headerCommands.%s", path, name), "%v", err)
+			continue
+		}
+		set(name, value, "headerCommands")
+	}
+}
+
+// secret sets value from file or command, if one of them is set. Only one of the
+// value, the file and the command may be set.
+func (v *validator) secret(value *string, file, command, path, key, dir string) {
+	set := 0
+	for _, s := range []string{*value, file, command} {
+		if s != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		v.check(true, path+"."+key, "set only one of %s, %sFile and %sCommand", key, key, key)
+		return
+	}
+
+	var err error
+	switch {
+	case file != "":
+		*value, err = readSecretFile(file, dir)
+		v.check(err != nil, path+"."+key+"File", "%v", err)
+	case command != "":
+		*value, err = runSecretCommand(command, dir)
+		v.check(err != nil, path+"."+key+"Command", "%v", err)
+	}
+}
+
+// readSecretFile returns the content of a secret file, like a Docker or Kubernetes
+// secret, without surrounding whitespace.
+func readSecretFile(path, dir string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrap(err, "could not read secret")
+	}
+
+	secret := strings.TrimSpace(string(content))
+	if secret == "" {
+		return "", errors.Errorf("secret file %q is empty", path)
+	}
+
+	return secret, nil
+}
+
+// runSecretCommand runs a command with the shell, like a password manager CLI, and
+// returns what it prints without surrounding whitespace.
+func runSecretCommand(command, dir string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Dir = dir
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.Wrapf(err, "secret command failed: %s", msg)
+		}
+		return "", errors.Wrap(err, "secret command failed")
+	}
+
+	secret := strings.TrimSpace(string(out))
+	if secret == "" {
+		return "", errors.New("secret command printed nothing")
+	}
+
+	return secret, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,314 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// ArrTypes are the arr types omegabrr supports.
+var ArrTypes = []ArrType{ArrTypeRadarr, ArrTypeSonarr, ArrTypeReadarr, ArrTypeLidarr, ArrTypeWhisparr}
+
+// ListTypes are the list types omegabrr supports.
+var ListTypes = []ListType{ListTypeTrakt, ListTypeMdblist, ListTypeMetacritic, ListTypePlaintext, ListTypeSteam}
+
+// SetOperations are the operations a set can combine its sources with.
+var SetOperations = []SetOperation{SetOperationUnion, SetOperationIntersection, SetOperationDifference}
+
+// ArrEmits are what each arr type can emit. Types not listed don't support emit.
+var ArrEmits = map[ArrType][]ArrEmit{
+	ArrTypeLidarr:   {ArrEmitAlbums, ArrEmitArtists},
+	ArrTypeReadarr:  {ArrEmitTitles, ArrEmitAuthorTitles, ArrEmitAuthors, ArrEmitSeries},
+	ArrTypeWhisparr: {ArrEmitTitles, ArrEmitSites, ArrEmitPerformers},
+}
+
+// DefaultArrEmits are what arr types emit when emit isn't set.
+var DefaultArrEmits = map[ArrType][]ArrEmit{
+	ArrTypeLidarr:   {ArrEmitAlbums, ArrEmitArtists},
+	ArrTypeReadarr:  {ArrEmitTitles},
+	ArrTypeWhisparr: {ArrEmitTitles},
+}
+
+// AlbumTypes are the Lidarr album types, the primary types first.
+var AlbumTypes = []AlbumType{
+	"Album", "EP", "Single", "Broadcast", "Other",
+	"Compilation", "Soundtrack", "Spokenword", "Interview", "Audiobook", "Audio drama", "Live", "Remix", "DJ-mix", "Mixtape/Street", "Demo",
+}
+
+// CreditModes are the ways to handle Various Artists and featured artist credits.
+var CreditModes = []CreditMode{CreditModeKeep, CreditModeNormalize, CreditModeSkip}
+
+// ValidationError is a problem with the value at a path in config.yaml, like clients.arr[0].host.
+// File is set for problems in an included file.
+type ValidationError struct {
+	File    string
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	msg := e.Message
+	if e.Path != "" {
+		msg = fmt.Sprintf("%s: %s", e.Path, msg)
+	}
+	if e.File != "" {
+		msg = fmt.Sprintf("%s: %s", e.File, msg)
+	}
+	return msg
+}
+
+// ValidationErrors are all problems found in a config.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("invalid config: %s", strings.Join(msgs, "; "))
+}
+
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) check(condition bool, path, format string, args ...interface{}) {
+	if condition {
+		v.errs = append(v.errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+func (v *validator) required(value, path string) {
+	v.check(value == "", path, "required")
+}
+
+func (v *validator) url(value, path string) {
+	if value == "" {
+		v.required(value, path)
+		return
+	}
+
+	u, err := url.Parse(value)
+	v.check(err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "", path, "must be an http or https URL, got %q", value)
+}
+
+func (v *validator) schedule(value, path string) {
+	if value == "" {
+		return
+	}
+
+	_, err := cron.ParseStandard(value)
+	v.check(err != nil, path, "invalid cron expression %q: %v", value, err)
+}
+
+// arrOptions checks the options only some arr types support.
+func (v *validator) arrOptions(arr *ArrConfig, path string) {
+	if emits, ok := ArrEmits[arr.Type]; !ok {
+		v.check(len(arr.Emit) > 0, path+".emit", "not supported for %s", arr.Type)
+	} else {
+		for i, emit := range arr.Emit {
+			v.check(!oneOf(emit, emits), fmt.Sprintf("%s.emit[%d]", path, i), "unknown value %q for %s, must be one of: %s", emit, arr.Type, joinValues(emits))
+		}
+	}
+
+	lidarr := arr.Type == ArrTypeLidarr
+	v.check(!lidarr && len(arr.AlbumTypes) > 0, path+".albumTypes", "only supported for lidarr")
+	v.check(!lidarr && arr.ReleasedWithin != 0, path+".releasedWithin", "only supported for lidarr")
+	v.check(!lidarr && arr.VariousArtists != "", path+".variousArtists", "only supported for lidarr")
+	v.check(!lidarr && arr.FeaturedArtists != "", path+".featuredArtists", "only supported for lidarr")
+	// only album titles go into Match releases, so artists alone would leave it empty
+	v.check(lidarr && arr.MatchRelease && arr.Emits(ArrEmitArtists) && !arr.Emits(ArrEmitAlbums), path+".emit", "artists are not written to match releases, emit albums or turn off matchRelease")
+
+	readarr := arr.Type == ArrTypeReadarr
+	v.check(!readarr && len(arr.QualityProfiles) > 0, path+".qualityProfiles", "only supported for readarr")
+	v.check(!readarr && len(arr.MetadataProfiles) > 0, path+".metadataProfiles", "only supported for readarr")
+
+	whisparr := arr.Type == ArrTypeWhisparr
+	v.check(!whisparr && arr.WhisparrVersion != 0, path+".whisparrVersion", "only supported for whisparr")
+	v.check(whisparr && !slices.Contains([]int{0, 2, 3}, arr.WhisparrVersion), path+".whisparrVersion", "unknown whisparr version %d, must be 2 or 3", arr.WhisparrVersion)
+	if whisparr && arr.WhisparrVersion == 2 {
+		// v2 is built on Sonarr, it has no sites or performers
+		for i, emit := range arr.Emit {
+			v.check(emit != ArrEmitTitles, fmt.Sprintf("%s.emit[%d]", path, i), "%s needs whisparr v3", emit)
+		}
+	}
+
+	for i, albumType := range arr.AlbumTypes {
+		v.check(!oneOf(albumType, AlbumTypes), fmt.Sprintf("%s.albumTypes[%d]", path, i), "unknown album type %q, must be one of: %s", albumType, joinValues(AlbumTypes))
+	}
+	v.check(arr.ReleasedWithin < 0, path+".releasedWithin", "must not be negative")
+	v.check(arr.VariousArtists != "" && !oneOf(arr.VariousArtists, CreditModes), path+".variousArtists", "unknown value %q, must be one of: %s", arr.VariousArtists, joinValues(CreditModes))
+	v.check(arr.FeaturedArtists != "" && !oneOf(arr.FeaturedArtists, CreditModes), path+".featuredArtists", "unknown value %q, must be one of: %s", arr.FeaturedArtists, joinValues(CreditModes))
+}
+
+func oneOf[T ~string](value T, allowed []T) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+func joinValues[T ~string](values []T) string {
+	return strings.Join(enumValues(values), ", ")
+}
+
+// Validate returns every problem with the config, or nil if there are none.
+func (c *Config) Validate() ValidationErrors {
+	v := &validator{}
+
+	v.check(c.Version > ConfigVersion, "version", "version %d is newer than this omegabrr supports (%d), upgrade omegabrr", c.Version, ConfigVersion)
+
+	v.required(c.Schedule, "schedule")
+	v.schedule(c.Schedule, "schedule")
+
+	if tz := c.Scheduler.Timezone; tz != "" {
+		_, err := time.LoadLocation(tz)
+		v.check(err != nil, "scheduler.timezone", "unknown timezone %q", tz)
+	}
+
+	if a := c.Clients.Autobrr; a == nil {
+		v.check(true, "clients.autobrr", "required")
+	} else {
+		v.url(a.Host, "clients.autobrr.host")
+		// a failing apikeyFile or apikeyCommand is reported on its own
+		if a.ApikeyFile == "" && a.ApikeyCommand == "" {
+			v.required(a.Apikey, "clients.autobrr.apikey")
+		}
+	}
+
+	if d := c.Defaults.Arr; d != nil {
+		v.check(d.Name != "", "defaults.arr.name", "can't be set in defaults, names must be unique")
+	}
+	if d := c.Defaults.Lists; d != nil {
+		v.check(d.Name != "", "defaults.lists.name", "can't be set in defaults, names must be unique")
+	}
+
+	// names are how sets refer to arrs and lists, so they must be unique across all of them and sets
+	names := make(map[string]string)
+	unique := func(name, path string) {
+		if name == "" {
+			v.required(name, path)
+			return
+		}
+		if first, ok := names[name]; ok {
+			v.check(true, path, "duplicate name %q, already used by %s", name, c.describe(first))
+			return
+		}
+		names[name] = path
+	}
+
+	// arrs and lists used by a set don't need filters of their own
+	setSources := make(map[string]struct{})
+	for _, set := range c.Sets {
+		for _, name := range set.Sources {
+			setSources[name] = struct{}{}
+		}
+	}
+
+	for i, arr := range c.Clients.Arr {
+		path := fmt.Sprintf("clients.arr[%d]", i)
+
+		unique(arr.Name, path+".name")
+		v.check(!oneOf(arr.Type, ArrTypes), path+".type", "unknown arr type %q, must be one of: %s", arr.Type, joinValues(ArrTypes))
+		v.url(arr.Host, path+".host")
+		// secrets of disabled arrs are not resolved
+		if arr.ApikeyFile == "" && arr.ApikeyCommand == "" && arr.IsEnabled() {
+			v.required(arr.Apikey, path+".apikey")
+		}
+		v.schedule(arr.Schedule, path+".schedule")
+
+		_, inSet := setSources[arr.Name]
+		v.check(len(arr.Filters) < 1 && !inSet, path+".filters", "at least one filter is required")
+
+		v.arrOptions(arr, path)
+	}
+
+	for i, list := range c.Lists {
+		path := fmt.Sprintf("lists[%d]", i)
+
+		unique(list.Name, path+".name")
+		v.check(!oneOf(list.Type, ListTypes), path+".type", "unknown list type %q, must be one of: %s", list.Type, joinValues(ListTypes))
+		v.url(list.URL, path+".url")
+		v.schedule(list.Schedule, path+".schedule")
+
+		_, inSet := setSources[list.Name]
+		v.check(len(list.Filters) < 1 && !inSet, path+".filters", "at least one filter is required")
+	}
+
+	for i, set := range c.Sets {
+		path := fmt.Sprintf("sets[%d]", i)
+
+		unique(set.Name, path+".name")
+		v.check(!oneOf(set.Operation, SetOperations), path+".operation", "unknown set operation %q, must be one of: %s", set.Operation, joinValues(SetOperations))
+		v.check(len(set.Sources) < 1, path+".sources", "at least one source is required")
+		v.check(len(set.Filters) < 1, path+".filters", "at least one filter is required")
+		v.schedule(set.Schedule, path+".schedule")
+	}
+
+	// checked last, so every name is known. Sets combine arrs and lists, not other sets
+	for i, set := range c.Sets {
+		for j, name := range set.Sources {
+			path := fmt.Sprintf("sets[%d].sources[%d]", i, j)
+
+			first, ok := names[name]
+			v.check(!ok, path, "unknown arr or list %q", name)
+			v.check(ok && strings.HasPrefix(first, "sets["), path, "%q is a set, sets can only combine arrs and lists", name)
+		}
+	}
+
+	return c.locate(v.errs)
+}
+
+// validateEnv returns an error for every environment variable referenced in the
+// raw config that is not set, since it would silently expand to an empty value.
+func validateEnv(content []byte) ValidationErrors {
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		// reported when the expanded config is parsed
+		return nil
+	}
+
+	v := &validator{}
+
+	walkScalars(&root, "", func(path, value string) {
+		os.Expand(value, func(name string) string {
+			_, ok := os.LookupEnv(name)
+			v.check(!ok, path, "environment variable %s is not set", name)
+			return ""
+		})
+	})
+
+	return v.errs
+}
+
+// walkScalars calls fn with the path and value of every scalar in the YAML tree.
+func walkScalars(node *yaml.Node, path string, fn func(path, value string)) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			walkScalars(n, path, fn)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			walkScalars(node.Content[i+1], key, fn)
+		}
+	case yaml.SequenceNode:
+		for i, n := range node.Content {
+			walkScalars(n, fmt.Sprintf("%s[%d]", path, i), fn)
+		}
+	case yaml.ScalarNode:
+		fn(path, node.Value)
+	}
+}
@@ -0,0 +1,224 @@
+package history
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var ErrNotFound = errors.New("not found")
+
+var (
+	runsBucket    = []byte("runs")
+	filtersBucket = []byte("filters")
+)
+
+// Store keeps the run history and the titles last written to each filter in an
+// embedded database in the state directory.
+//
+// The database is only opened for the duration of each call, so the CLI can
+// read the history while the service is running.
+type Store struct {
+	path      string
+	retention int
+
+	mu sync.Mutex
+}
+
+func NewStore(dir string, retention int) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrapf(err, "could not create state directory %q", dir)
+	}
+
+	s := &Store{
+		path:      filepath.Join(dir, "omegabrr.db"),
+		retention: retention,
+	}
+
+	err := s.update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{runsBucket, filtersBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open history database %q", s.path)
+	}
+
+	return s, nil
+}
+
+func (s *Store) open() (*bolt.DB, error) {
+	return bolt.Open(s.path, 0o600, &bolt.Options{Timeout: 10 * time.Second})
+}
+
+func (s *Store) update(fn func(tx *bolt.Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(fn)
+}
+
+func (s *Store) view(fn func(tx *bolt.Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(fn)
+}
+
+// SaveRun stores a run and drops the oldest runs beyond the retention limit.
+func (s *Store) SaveRun(run *domain.RunReport) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(runsBucket)
+		if err := b.Put([]byte(run.ID), data); err != nil {
+			return err
+		}
+
+		if s.retention <= 0 {
+			return nil
+		}
+
+		// run IDs start with their timestamp, so keys are ordered oldest first
+		var keys [][]byte
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+
+		if len(keys) <= s.retention {
+			return nil
+		}
+
+		stale := keys[:len(keys)-s.retention]
+
+		for _, k := range stale {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetRun returns a single run by ID.
+func (s *Store) GetRun(id string) (*domain.RunReport, error) {
+	var run domain.RunReport
+
+	err := s.view(func(tx *bolt.Tx) error {
+		data := tx.Bucket(runsBucket).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &run)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+// ListRuns returns up to limit runs, newest first. A limit of 0 returns all of them.
+func (s *Store) ListRuns(limit int) ([]*domain.RunReport, error) {
+	var runs []*domain.RunReport
+
+	err := s.view(func(tx *bolt.Tx) error {
+		c := tx.Bucket(runsBucket).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			if limit > 0 && len(runs) >= limit {
+				break
+			}
+
+			var run domain.RunReport
+			if err := json.Unmarshal(v, &run); err != nil {
+				return errors.Wrapf(err, "could not decode run %s", k)
+			}
+			runs = append(runs, &run)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// FilterState returns what was last written to a filter, or nil if nothing was.
+func (s *Store) FilterState(filterID int) (*domain.FilterState, error) {
+	var state *domain.FilterState
+
+	err := s.view(func(tx *bolt.Tx) error {
+		data := tx.Bucket(filtersBucket).Get([]byte(strconv.Itoa(filterID)))
+		if data == nil {
+			return nil
+		}
+		state = &domain.FilterState{}
+		return json.Unmarshal(data, state)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// FilterStates returns the last written state of every filter.
+func (s *Store) FilterStates() ([]*domain.FilterState, error) {
+	var states []*domain.FilterState
+
+	err := s.view(func(tx *bolt.Tx) error {
+		return tx.Bucket(filtersBucket).ForEach(func(k, v []byte) error {
+			var state domain.FilterState
+			if err := json.Unmarshal(v, &state); err != nil {
+				return errors.Wrapf(err, "could not decode filter %s", k)
+			}
+			states = append(states, &state)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
+func (s *Store) SaveFilterState(state *domain.FilterState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(filtersBucket).Put([]byte(strconv.Itoa(state.ID)), data)
+	})
+}
@@ -0,0 +1,46 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_runs(t *testing.T) {
+	store, err := NewStore(t.TempDir(), 2)
+	require.NoError(t, err)
+
+	for _, id := range []string{"20240101-000000-aaaaaa", "20240102-000000-bbbbbb", "20240103-000000-cccccc"} {
+		require.NoError(t, store.SaveRun(&domain.RunReport{ID: id, Trigger: domain.RunTriggerCLI}))
+	}
+
+	runs, err := store.ListRuns(0)
+	require.NoError(t, err)
+
+	// the oldest run is dropped and the newest comes first
+	require.Len(t, runs, 2)
+	assert.Equal(t, "20240103-000000-cccccc", runs[0].ID)
+	assert.Equal(t, "20240102-000000-bbbbbb", runs[1].ID)
+
+	_, err = store.GetRun("20240101-000000-aaaaaa")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestStore_filterState(t *testing.T) {
+	store, err := NewStore(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	state, err := store.FilterState(1)
+	require.NoError(t, err)
+	assert.Nil(t, state)
+
+	require.NoError(t, store.SaveFilterState(&domain.FilterState{ID: 1, Titles: []string{"Dune"}, UpdatedAt: time.Now()}))
+
+	state, err = store.FilterState(1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Dune"}, state.Titles)
+}
@@ -0,0 +1,29 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+type configHandler struct {
+	cfg *domain.ConfigHolder
+}
+
+func newConfigHandler(cfg *domain.ConfigHolder) *configHandler {
+	return &configHandler{
+		cfg: cfg,
+	}
+}
+
+func (h configHandler) Routes(r chi.Router) {
+	r.Get("/", h.get)
+}
+
+// get returns the config in use with its secrets redacted.
+func (h configHandler) get(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, h.cfg.Get().Redacted().Map())
+}
@@ -4,16 +4,18 @@ import "net/http"
 
 func (s Server) isAuthenticated(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiToken := s.cfg.Get().Server.APIToken
+
 		if token := r.Header.Get("X-API-Token"); token != "" {
 			// check header
-			if token != s.cfg.Server.APIToken {
+			if token != apiToken {
 				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 				return
 			}
 
 		} else if key := r.URL.Query().Get("apikey"); key != "" {
 			// check query param lke ?apikey=TOKEN
-			if key != s.cfg.Server.APIToken {
+			if key != apiToken {
 				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 				return
 			}
@@ -22,3 +24,23 @@ func (s Server) isAuthenticated(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// requireAPIToken only lets requests with the API token through, for routes that
+// must not be reachable without it, even when no apiToken is configured.
+func (s Server) requireAPIToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiToken := s.cfg.Get().Server.APIToken
+
+		token := r.Header.Get("X-API-Token")
+		if token == "" {
+			token = r.URL.Query().Get("apikey")
+		}
+
+		if apiToken == "" || token != apiToken {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
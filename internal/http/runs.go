@@ -0,0 +1,184 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/autobrr/omegabrr/internal/history"
+	"github.com/autobrr/omegabrr/internal/processor"
+	"github.com/autobrr/omegabrr/internal/scheduler"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/pkg/errors"
+)
+
+type runsHandler struct {
+	store            *history.Store
+	processorService *processor.Service
+	coordinator      *scheduler.Coordinator
+
+	// requireToken guards cancelling runs, which must not be possible without the API token
+	requireToken func(http.Handler) http.Handler
+}
+
+func newRunsHandler(store *history.Store, processorSvc *processor.Service, coordinator *scheduler.Coordinator, requireToken func(http.Handler) http.Handler) *runsHandler {
+	return &runsHandler{
+		store:            store,
+		processorService: processorSvc,
+		coordinator:      coordinator,
+		requireToken:     requireToken,
+	}
+}
+
+func (h runsHandler) Routes(r chi.Router) {
+	r.Get("/", h.list)
+	r.Get("/current", h.current)
+	r.Get("/{runID}", h.get)
+	r.With(h.requireToken).Delete("/{runID}", h.cancel)
+	r.Get("/{runID}/events", h.events)
+}
+
+type errorResponse struct {
+	Message string `json:"message"`
+}
+
+func renderError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	render.Status(r, status)
+	render.JSON(w, r, errorResponse{Message: err.Error()})
+}
+
+func (h runsHandler) list(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		renderError(w, r, http.StatusServiceUnavailable, errors.New("run history is not available"))
+		return
+	}
+
+	limit := 20
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 0 {
+			renderError(w, r, http.StatusBadRequest, errors.New("limit must be a positive number"))
+			return
+		}
+		limit = n
+	}
+
+	runs, err := h.store.ListRuns(limit)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	render.JSON(w, r, runs)
+}
+
+// current returns the run in progress and the run waiting to start, if any.
+func (h runsHandler) current(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, h.coordinator.Status())
+}
+
+func (h runsHandler) get(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "runID")
+
+	// runs in progress are only known to the processor
+	if run, ok := h.processorService.GetRun(id); ok {
+		render.JSON(w, r, run)
+		return
+	}
+
+	if h.store == nil {
+		renderError(w, r, http.StatusNotFound, errors.Errorf("run not found: %s", id))
+		return
+	}
+
+	run, err := h.store.GetRun(id)
+	if err != nil {
+		if errors.Is(err, history.ErrNotFound) {
+			renderError(w, r, http.StatusNotFound, err)
+			return
+		}
+		renderError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	render.JSON(w, r, run)
+}
+
+// cancel stops a run in progress.
+func (h runsHandler) cancel(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "runID")
+
+	if h.processorService.Cancel(id) {
+		render.NoContent(w, r)
+		return
+	}
+
+	if _, ok := h.processorService.GetRun(id); ok {
+		renderError(w, r, http.StatusConflict, errors.Errorf("run is not in progress: %s", id))
+		return
+	}
+
+	renderError(w, r, http.StatusNotFound, errors.Errorf("run not found: %s", id))
+}
+
+// events streams the progress of a run as server-sent events until it finishes.
+// A run that already finished gets a single run-finished event.
+func (h runsHandler) events(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "runID")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		renderError(w, r, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	events, unsubscribe, ok := h.processorService.Subscribe(id)
+	if !ok {
+		run, ok := h.processorService.GetRun(id)
+		if !ok {
+			renderError(w, r, http.StatusNotFound, errors.Errorf("run not in progress or finished recently: %s", id))
+			return
+		}
+
+		writeEventStreamHeaders(w)
+		writeEvent(w, processor.Event{Type: processor.EventRunFinished, RunID: run.ID, Time: run.FinishedAt, Run: run})
+		flusher.Flush()
+		return
+	}
+	defer unsubscribe()
+
+	writeEventStreamHeaders(w)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			writeEvent(w, ev)
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEventStreamHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+}
+
+func writeEvent(w http.ResponseWriter, ev processor.Event) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+}
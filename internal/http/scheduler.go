@@ -0,0 +1,49 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/autobrr/omegabrr/internal/scheduler"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+type schedulerHandler struct {
+	schedulerService *scheduler.Service
+
+	// requireToken guards pausing and resuming, which must not be possible without the API token
+	requireToken func(http.Handler) http.Handler
+}
+
+func newSchedulerHandler(schedulerSvc *scheduler.Service, requireToken func(http.Handler) http.Handler) *schedulerHandler {
+	return &schedulerHandler{
+		schedulerService: schedulerSvc,
+		requireToken:     requireToken,
+	}
+}
+
+func (h schedulerHandler) Routes(r chi.Router) {
+	r.Get("/", h.status)
+
+	r.Group(func(r chi.Router) {
+		r.Use(h.requireToken)
+
+		r.Post("/pause", h.pause)
+		r.Post("/resume", h.resume)
+	})
+}
+
+func (h schedulerHandler) status(w http.ResponseWriter, r *http.Request) {
+	render.JSON(w, r, h.schedulerService.Status())
+}
+
+func (h schedulerHandler) pause(w http.ResponseWriter, r *http.Request) {
+	h.schedulerService.Pause()
+	render.JSON(w, r, h.schedulerService.Status())
+}
+
+func (h schedulerHandler) resume(w http.ResponseWriter, r *http.Request) {
+	h.schedulerService.Resume()
+	render.JSON(w, r, h.schedulerService.Status())
+}
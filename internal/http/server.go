@@ -6,7 +6,9 @@ import (
 	"net/http"
 
 	"github.com/autobrr/omegabrr/internal/domain"
+	"github.com/autobrr/omegabrr/internal/history"
 	"github.com/autobrr/omegabrr/internal/processor"
+	"github.com/autobrr/omegabrr/internal/scheduler"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -14,20 +16,27 @@ import (
 )
 
 type Server struct {
-	cfg *domain.Config
+	cfg *domain.ConfigHolder
 
 	processorService *processor.Service
+	coordinator      *scheduler.Coordinator
+	schedulerService *scheduler.Service
+	historyStore     *history.Store
 }
 
-func NewServer(config *domain.Config, processorService *processor.Service) Server {
+func NewServer(config *domain.ConfigHolder, processorService *processor.Service, coordinator *scheduler.Coordinator, schedulerService *scheduler.Service, historyStore *history.Store) Server {
 	return Server{
 		cfg:              config,
 		processorService: processorService,
+		coordinator:      coordinator,
+		schedulerService: schedulerService,
+		historyStore:     historyStore,
 	}
 }
 
 func (s Server) Open() error {
-	addr := fmt.Sprintf("%v:%v", s.cfg.Server.Host, s.cfg.Server.Port)
+	cfg := s.cfg.Get()
+	addr := fmt.Sprintf("%v:%v", cfg.Server.Host, cfg.Server.Port)
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		return errors.Wrap(err, "error opening http server")
@@ -56,7 +65,11 @@ func (s Server) Handler() http.Handler {
 
 		r.Route("/api", func(r chi.Router) {
 			r.Route("/", newProcessorHandler(s.processorService).Routes)
-			r.Route("/webhook", newWebhookHandler(s.cfg, s.processorService).Routes)
+			r.Route("/webhook", newWebhookHandler(s.coordinator).Routes)
+			r.Route("/scheduler", newSchedulerHandler(s.schedulerService, s.requireAPIToken).Routes)
+			r.Route("/runs", newRunsHandler(s.historyStore, s.processorService, s.coordinator, s.requireAPIToken).Routes)
+
+			r.With(s.requireAPIToken).Route("/config", newConfigHandler(s.cfg).Routes)
 		})
 	})
 
@@ -2,59 +2,89 @@ package http
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/autobrr/omegabrr/internal/domain"
 	"github.com/autobrr/omegabrr/internal/processor"
+	"github.com/autobrr/omegabrr/internal/scheduler"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
+	"github.com/pkg/errors"
 )
 
 type webhookHandler struct {
-	cfg              *domain.Config
-	processorService *processor.Service
+	coordinator *scheduler.Coordinator
 }
 
-func newWebhookHandler(cfg *domain.Config, processorSvc *processor.Service) *webhookHandler {
+func newWebhookHandler(coordinator *scheduler.Coordinator) *webhookHandler {
 	return &webhookHandler{
-		cfg:              cfg,
-		processorService: processorSvc,
+		coordinator: coordinator,
 	}
 }
 
 func (h webhookHandler) Routes(r chi.Router) {
-	r.Get("/trigger", h.run)
-	r.Post("/trigger", h.run)
-	r.Get("/trigger/arr", h.arr)
-	r.Get("/trigger/lists", h.lists)
-	r.Post("/trigger/arr", h.arr)
-	r.Post("/trigger/lists", h.lists)
+	r.Get("/trigger", h.trigger(""))
+	r.Post("/trigger", h.trigger(""))
+	r.Get("/trigger/arr", h.trigger(processor.SourceKindArr))
+	r.Get("/trigger/lists", h.trigger(processor.SourceKindList))
+	r.Post("/trigger/arr", h.trigger(processor.SourceKindArr))
+	r.Post("/trigger/lists", h.trigger(processor.SourceKindList))
 }
 
-func (h webhookHandler) run(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	errArrs := h.processorService.ProcessArrs(ctx, false)
-	errLists := h.processorService.ProcessLists(ctx, false)
-
-	if errArrs != nil || errLists != nil {
-		render.NoContent(w, r)
-	} else {
-		render.Status(r, http.StatusOK)
-	}
+type asyncResponse struct {
+	ID     string           `json:"id"`
+	Status domain.RunStatus `json:"status"`
 }
 
-func (h webhookHandler) arr(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	if err := h.processorService.ProcessArrs(ctx, false); err != nil {
-		render.NoContent(w, r)
+// trigger runs the sources of the given kind, or all of them when kind is empty,
+// and responds with the run report. With ?async=true it responds right away with
+// the ID of the run, which can be polled at /api/runs/{id}. Async triggers arriving
+// close together are merged into one run by the coordinator. A caller waiting for
+// the report is not held back by the debounce window, its run starts right away
+// and takes any held back triggers with it.
+func (h webhookHandler) trigger(kind processor.SourceKind) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts := processor.RunOptions{Trigger: domain.RunTriggerWebhook, Kind: kind}
+
+		async := false
+		if v := r.URL.Query().Get("async"); v != "" {
+			var err error
+			if async, err = strconv.ParseBool(v); err != nil {
+				renderError(w, r, http.StatusBadRequest, errors.New("async must be true or false"))
+				return
+			}
+		}
+
+		if async {
+			ticket := h.coordinator.Trigger(opts)
+
+			w.Header().Set("Location", "/api/runs/"+ticket.ID())
+			render.Status(r, http.StatusAccepted)
+			render.JSON(w, r, asyncResponse{ID: ticket.ID(), Status: domain.RunStatusQueued})
+			return
+		}
+
+		run, err := h.coordinator.RunNow(opts).Wait(r.Context())
+		if err != nil {
+			// the client went away, the run carries on
+			return
+		}
+
+		render.Status(r, runStatusCode(run))
+		render.JSON(w, r, run)
 	}
-	render.Status(r, http.StatusOK)
 }
 
-func (h webhookHandler) lists(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	if err := h.processorService.ProcessLists(ctx, false); err != nil {
-		render.NoContent(w, r)
+// runStatusCode is 200 when the run went fine, 207 when some sources or filters
+// failed and 500 when nothing could be updated or the run was cancelled.
+func runStatusCode(run *domain.RunReport) int {
+	switch run.Result() {
+	case domain.RunStatusPartial:
+		return http.StatusMultiStatus
+	case domain.RunStatusFailed, domain.RunStatusCancelled:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusOK
 	}
-	render.Status(r, http.StatusOK)
 }
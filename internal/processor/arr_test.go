@@ -0,0 +1,27 @@
+package processor
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newArrServer serves the JSON responses by path, like /api/v1/tag, and fails the
+// test on other requests.
+func newArrServer(t *testing.T, responses map[string]string) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request: %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, response)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"net/http"
 	"runtime"
+	"time"
 
 	"github.com/autobrr/omegabrr/internal/buildinfo"
+	"github.com/autobrr/omegabrr/internal/domain"
+
+	"golift.io/starr"
 )
 
 func setUserAgent(req *http.Request) {
@@ -13,3 +17,18 @@ func setUserAgent(req *http.Request) {
 
 	req.Header.Set("User-Agent", agent)
 }
+
+func newStarrConfig(cfg *domain.ArrConfig) *starr.Config {
+	c := starr.New(cfg.Apikey, cfg.Host, 60*time.Second)
+
+	if cfg.BasicAuth != nil {
+		if cfg.BasicAuth.User != "" {
+			c.HTTPUser = cfg.BasicAuth.User
+		}
+		if cfg.BasicAuth.Pass != "" {
+			c.HTTPPass = cfg.BasicAuth.Pass
+		}
+	}
+
+	return c
+}
@@ -0,0 +1,36 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+)
+
+type EventType string
+
+var (
+	EventRunStarted     EventType = "run-started"
+	EventSourceStarted  EventType = "source-started"
+	EventSourceFinished EventType = "source-finished"
+	EventFilterUpdated  EventType = "filter-updated"
+	EventRunFinished    EventType = "run-finished"
+)
+
+// Event reports the progress of a run to its subscribers.
+type Event struct {
+	Type   EventType            `json:"type"`
+	RunID  string               `json:"runId"`
+	Time   time.Time            `json:"time"`
+	Source *domain.SourceReport `json:"source,omitempty"`
+	Filter *domain.FilterReport `json:"filter,omitempty"`
+	Run    *domain.RunReport    `json:"run,omitempty"`
+}
+
+// emitter sends the events of a single run.
+type emitter func(Event)
+
+func (e emitter) emit(ev Event) {
+	if e != nil {
+		e(ev)
+	}
+}
@@ -0,0 +1,74 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/autobrr/omegabrr/pkg/autobrr"
+)
+
+// fingerprints remembers what was last written to each filter.
+type fingerprints struct {
+	mu sync.Mutex
+	m  map[int]string
+}
+
+func newFingerprints() *fingerprints {
+	return &fingerprints{m: make(map[int]string)}
+}
+
+func (f *fingerprints) get(filterID int) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fp, ok := f.m[filterID]
+	return fp, ok
+}
+
+func (f *fingerprints) set(filterID int, fp string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.m[filterID] = fp
+}
+
+// fingerprint hashes the canonical payload of a filter update.
+// Patterns are sorted before they get here, so equal content gives an equal hash.
+func fingerprint(f autobrr.UpdateFilter) string {
+	b, _ := json.Marshal(f)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// unchanged reports whether the update would leave the filter as it is. It compares
+// with the filter in autobrr when compareRemote is set, and with the previous run otherwise.
+func (s Service) unchanged(ctx context.Context, filterID int, f autobrr.UpdateFilter) (bool, error) {
+	if s.cfg.Processing.CompareRemote {
+		current, err := s.autobrrClient.GetFilterByID(ctx, filterID)
+		if err != nil {
+			return false, err
+		}
+
+		return sameAsFilter(f, current), nil
+	}
+
+	if s.fingerprints == nil {
+		return false, nil
+	}
+
+	previous, ok := s.fingerprints.get(filterID)
+	return ok && previous == fingerprint(f), nil
+}
+
+// sameAsFilter compares the fields an update would write with the current filter.
+// match_releases is only sent when set, so it is only compared then.
+func sameAsFilter(f autobrr.UpdateFilter, current *autobrr.Filter) bool {
+	if f.Shows != current.Shows || f.Albums != current.Albums || f.Artists != current.Artists {
+		return false
+	}
+
+	return f.MatchReleases == "" || f.MatchReleases == current.MatchReleases
+}
@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+	"github.com/autobrr/omegabrr/pkg/autobrr"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_update_skipsUnchanged(t *testing.T) {
+	var patches int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			patches++
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	s := Service{
+		cfg:           &domain.Config{},
+		autobrrClient: autobrr.NewClient(ts.URL, "key"),
+		fingerprints:  newFingerprints(),
+	}
+	s.cfg.Processing.SkipUnchanged = true
+
+	newUpdate := func(titles ...string) *filterUpdate {
+		set := make(map[string]struct{})
+		for _, title := range titles {
+			set[title] = struct{}{}
+		}
+		return &filterUpdate{id: 1, titles: set, patterns: map[Field]map[string]struct{}{FieldShows: set}}
+	}
+
+	ctx := context.Background()
+
+	assert.Equal(t, domain.FilterStatusUpdated, s.update(ctx, "run-1", newUpdate("Dune", "Alien"), false).Status)
+	assert.Equal(t, domain.FilterStatusUnchanged, s.update(ctx, "run-2", newUpdate("Alien", "Dune"), false).Status)
+	assert.Equal(t, 1, patches)
+
+	assert.Equal(t, domain.FilterStatusUpdated, s.update(ctx, "run-3", newUpdate("Alien", "Dune", "Heat"), false).Status)
+	assert.Equal(t, 2, patches)
+}
+
+func TestService_update_failureDoesNotBlockSiblings(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/filters/1" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	s := Service{
+		cfg:           &domain.Config{},
+		autobrrClient: autobrr.NewClient(ts.URL, "key"),
+		fingerprints:  newFingerprints(),
+	}
+
+	titles := map[string]struct{}{"Dune": {}}
+	ctx := context.Background()
+
+	failed := s.update(ctx, "run-1", &filterUpdate{id: 1, titles: titles, patterns: map[Field]map[string]struct{}{FieldShows: titles}}, false)
+	assert.Equal(t, domain.FilterStatusFailed, failed.Status)
+	assert.Equal(t, domain.ErrorClassUpdate, failed.Error.Class)
+
+	updated := s.update(ctx, "run-1", &filterUpdate{id: 2, titles: titles, patterns: map[Field]map[string]struct{}{FieldShows: titles}}, false)
+	assert.Equal(t, domain.FilterStatusUpdated, updated.Status)
+	assert.Equal(t, 1, updated.Titles)
+}
+
+func TestService_update_skipsWhenStopped(t *testing.T) {
+	s := Service{cfg: &domain.Config{}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	titles := map[string]struct{}{"Dune": {}}
+	fr := s.update(ctx, "run-1", &filterUpdate{id: 1, titles: titles, patterns: map[Field]map[string]struct{}{FieldShows: titles}}, false)
+
+	assert.Equal(t, domain.FilterStatusSkipped, fr.Status)
+	assert.Equal(t, domain.ErrorClassCancelled, fr.Error.Class)
+}
+
+func Test_sameAsFilter(t *testing.T) {
+	current := &autobrr.Filter{Shows: "Dune", MatchReleases: "*Heat*"}
+
+	assert.True(t, sameAsFilter(autobrr.UpdateFilter{Shows: "Dune"}, current))
+	assert.False(t, sameAsFilter(autobrr.UpdateFilter{Shows: "Dune", MatchReleases: "*Alien*"}, current))
+	assert.False(t, sameAsFilter(autobrr.UpdateFilter{Shows: "Alien"}, current))
+}
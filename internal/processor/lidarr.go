@@ -2,127 +2,226 @@ package processor
 
 import (
 	"context"
+	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/autobrr/omegabrr/internal/domain"
-	"github.com/autobrr/omegabrr/pkg/autobrr"
-	"github.com/pkg/errors"
 
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 	"golift.io/starr"
 	"golift.io/starr/lidarr"
 )
 
-func (s Service) lidarr(ctx context.Context, cfg *domain.ArrConfig, dryRun bool, brr *autobrr.Client) error {
-	l := log.With().Str("type", "lidarr").Str("client", cfg.Name).Logger()
+func init() {
+	RegisterArrSource(domain.ArrTypeLidarr, newLidarrSource)
+}
+
+// variousArtistsID is the MusicBrainz ID of Various Artists, Lidarr's artist for compilations.
+const variousArtistsID = "89ad4ac3-39f7-470e-963a-56509c546377"
+
+// variousArtistsName is how releases of compilations name Various Artists.
+const variousArtistsName = "VA"
+
+// featuredCredit matches a featured artist credit up to the end of a title or name,
+// like " (feat. Someone)", " (feat Someone)" or " ft. Someone". Without a bracket
+// feat and ft need their dot, so titles like "A Feat of Clay" are left alone.
+var featuredCredit = regexp.MustCompile(`(?i)\s*(?:[(\[](?:feat\.?|ft\.?|featuring)|\b(?:feat\.|ft\.|featuring))\s.*$`)
+
+type lidarrSource struct {
+	arrSource
+}
+
+func newLidarrSource(cfg *domain.ArrConfig) Source {
+	return &lidarrSource{arrSource{cfg: cfg, field: FieldAlbums}}
+}
 
-	l.Debug().Msgf("gathering titles...")
+func (s *lidarrSource) Fetch(ctx context.Context, logger *zerolog.Logger) (*Items, error) {
+	r := lidarr.New(newStarrConfig(s.cfg))
 
-	titles, artists, err := s.processLidarr(ctx, cfg, &l)
+	tags, err := s.fetchTags(ctx, r, logger)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	l.Debug().Msgf("got %v filter titles", len(titles))
+	// fetch every artist once and join them to their albums, instead of a request per album
+	artistList, err := r.GetArtistContext(ctx, "")
+	if err != nil {
+		return nil, err
+	}
 
-	// Process titles
-	var processedTitles []string
-	for _, title := range titles {
-		processedTitles = append(processedTitles, processTitle(title, cfg.MatchRelease)...)
+	artistsByID := make(map[int64]*lidarr.Artist, len(artistList))
+	for _, artist := range artistList {
+		artistsByID[artist.ID] = artist
 	}
 
-	// Update filter based on MatchRelease
-	var f autobrr.UpdateFilter
-	if cfg.MatchRelease {
-		joinedTitles := strings.Join(processedTitles, ",")
-		if len(joinedTitles) == 0 {
-			return nil
+	items := &Items{}
+
+	// with albums, artists come from the albums that are kept, so they are narrowed
+	// down the same way. Only without albums every selected artist is added
+	artists := artistList
+
+	if s.cfg.Emits(domain.ArrEmitAlbums) {
+		albums, err := r.GetAlbumContext(ctx, "")
+		if err != nil {
+			return nil, err
 		}
-		f = autobrr.UpdateFilter{MatchReleases: joinedTitles}
-	} else {
-		// Process artists only if MatchRelease is false
-		var processedArtists []string
-		for _, artist := range artists {
-			processedArtists = append(processedArtists, processTitle(artist, cfg.MatchRelease)...)
+
+		logger.Debug().Msgf("found %d albums to process", len(albums))
+
+		items.Titles, artists = s.albumTitles(albums, artistsByID, tags, time.Now(), logger)
+
+		logger.Debug().Msgf("from a total of %d albums we found %d titles by %d artists", len(albums), len(items.Titles), len(artists))
+	}
+
+	if s.cfg.Emits(domain.ArrEmitArtists) {
+		items.Artists = s.artistNames(artists, tags)
+
+		logger.Debug().Msgf("from a total of %d artists we found %d artists", len(artists), len(items.Artists))
+	}
+
+	return items, nil
+}
+
+// albumTitles returns the titles of the albums that are selected, have one of the
+// album types and were released recently enough, and the artists of those albums.
+func (s *lidarrSource) albumTitles(albums []*lidarr.Album, artistsByID map[int64]*lidarr.Artist, tags []*starr.Tag, now time.Time, logger *zerolog.Logger) ([]string, []*lidarr.Artist) {
+	var titles []string
+	var artists []*lidarr.Artist
+	seenArtists := make(map[int64]struct{})
+
+	for _, album := range albums {
+		artist, ok := artistsByID[album.ArtistID]
+		if !ok {
+			logger.Debug().Msgf("skipping album %q, its artist %d was not found", album.Title, album.ArtistID)
+			continue
 		}
 
-		joinedTitles := strings.Join(processedTitles, ",")
-		joinedArtists := strings.Join(processedArtists, ",")
-		if len(joinedTitles) == 0 && len(joinedArtists) == 0 {
-			return nil
+		// albums have no tags of their own, they are selected by the tags of their artist
+		if !s.selects(tags, album.Monitored && artist.Monitored, artist.Tags) {
+			continue
 		}
-		f = autobrr.UpdateFilter{Albums: joinedTitles, Artists: joinedArtists}
-	}
 
-	for _, filterID := range cfg.Filters {
-		l.Debug().Msgf("updating filter: %v", filterID)
+		if !s.hasAlbumType(album) || !s.releasedWithin(album, now) {
+			continue
+		}
 
-		if !dryRun {
-			if err := brr.UpdateFilterByID(ctx, filterID, f); err != nil {
-				l.Error().Err(err).Msgf("error updating filter: %v", filterID)
-				return errors.Wrapf(err, "error updating filter: %v", filterID)
-			}
+		if isVariousArtists(artist) && s.cfg.VariousArtists == domain.CreditModeSkip {
+			continue
 		}
 
-		l.Debug().Msgf("successfully updated filter: %v", filterID)
+		title, ok := s.credit(album.Title)
+		if !ok {
+			continue
+		}
+
+		titles = append(titles, title)
+
+		if _, exists := seenArtists[artist.ID]; !exists {
+			seenArtists[artist.ID] = struct{}{}
+			artists = append(artists, artist)
+		}
 	}
 
-	return nil
+	return titles, artists
 }
 
-func (s Service) processLidarr(ctx context.Context, cfg *domain.ArrConfig, logger *zerolog.Logger) ([]string, []string, error) {
-	c := starr.New(cfg.Apikey, cfg.Host, 60*time.Second)
+// artistNames returns the names of the selected artists, once each.
+func (s *lidarrSource) artistNames(artistList []*lidarr.Artist, tags []*starr.Tag) []string {
+	var names []string
+	seen := make(map[string]struct{})
+
+	for _, artist := range artistList {
+		if !s.selects(tags, artist.Monitored, artist.Tags) {
+			continue
+		}
+
+		name := artist.ArtistName
+		if isVariousArtists(artist) {
+			switch s.cfg.VariousArtists {
+			case domain.CreditModeSkip:
+				continue
+			case domain.CreditModeNormalize:
+				name = variousArtistsName
+			}
+		}
 
-	if cfg.BasicAuth != nil {
-		if cfg.BasicAuth.User != "" {
-			c.HTTPUser = cfg.BasicAuth.User
+		name, ok := s.credit(name)
+		if !ok {
+			continue
 		}
-		if cfg.BasicAuth.Pass != "" {
-			c.HTTPPass = cfg.BasicAuth.Pass
+
+		if _, exists := seen[name]; !exists {
+			seen[name] = struct{}{}
+			names = append(names, name)
 		}
 	}
 
-	r := lidarr.New(c)
+	return names
+}
 
-	albums, err := r.GetAlbumContext(ctx, "")
-	if err != nil {
-		return nil, nil, err
+// hasAlbumType reports whether the primary type of the album is one of the album
+// types, and so are all of its secondary types. Album alone includes only studio
+// albums, while Album and Live adds live albums.
+func (s *lidarrSource) hasAlbumType(album *lidarr.Album) bool {
+	if len(s.cfg.AlbumTypes) == 0 {
+		return true
 	}
 
-	var titles []string
-	var artists []string
-	seenArtists := make(map[string]struct{})
+	if !s.includesAlbumType(album.AlbumType) {
+		return false
+	}
 
-	for _, album := range albums {
-		if !album.Monitored {
-			continue // Skip unmonitored albums
+	for _, secondary := range album.SecondaryTypes {
+		if !s.includesAlbumType(fmt.Sprint(secondary)) {
+			return false
 		}
+	}
 
-		// Fetch the artist details
-		artist, err := r.GetArtistByIDContext(ctx, album.ArtistID)
-		if err != nil {
-			logger.Error().Err(err).Msgf("Error fetching artist details for album: %v", album.Title)
-			continue // Skip this album if there's an error fetching the artist
+	return true
+}
+
+func (s *lidarrSource) includesAlbumType(albumType string) bool {
+	for _, t := range s.cfg.AlbumTypes {
+		if strings.EqualFold(string(t), albumType) {
+			return true
 		}
+	}
+
+	return false
+}
 
-		if artist.Monitored {
-			processedTitles := processTitle(album.Title, cfg.MatchRelease)
-			titles = append(titles, processedTitles...)
+// releasedWithin reports whether the album was released within the configured
+// duration before now. Upcoming albums and albums without a date are kept.
+func (s *lidarrSource) releasedWithin(album *lidarr.Album, now time.Time) bool {
+	if s.cfg.ReleasedWithin <= 0 || album.ReleaseDate.IsZero() {
+		return true
+	}
 
-			// Debug logging
-			logger.Debug().Msgf("Processing artist: %s", artist.ArtistName)
+	return !album.ReleaseDate.Before(now.Add(-s.cfg.ReleasedWithin))
+}
 
-			if _, exists := seenArtists[artist.ArtistName]; !exists {
-				artists = append(artists, artist.ArtistName)
-				seenArtists[artist.ArtistName] = struct{}{}
-				logger.Debug().Msgf("Added artist: %s", artist.ArtistName) // Log when an artist is added
-			}
+// credit applies featuredArtists to an album title or artist name. It returns false
+// if it should be skipped.
+func (s *lidarrSource) credit(name string) (string, bool) {
+	if !featuredCredit.MatchString(name) {
+		return name, true
+	}
+
+	switch s.cfg.FeaturedArtists {
+	case domain.CreditModeSkip:
+		return "", false
+	case domain.CreditModeNormalize:
+		// keep names that are only a credit, like an album called "Featuring"
+		if stripped := featuredCredit.ReplaceAllString(name, ""); stripped != "" {
+			return stripped, true
 		}
 	}
 
-	logger.Debug().Msgf("Processed %d monitored albums with monitored artists, created %d titles, found %d unique artists", len(titles), len(titles), len(artists))
+	return name, true
+}
 
-	return titles, artists, nil
+func isVariousArtists(artist *lidarr.Artist) bool {
+	return artist.ForeignArtistID == variousArtistsID || strings.EqualFold(artist.ArtistName, "Various Artists")
 }
@@ -0,0 +1,169 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Artists are fetched in one request, so the server has no /api/v1/artist/{id}.
+func TestLidarr_Fetch(t *testing.T) {
+	ts := newArrServer(t, map[string]string{
+		"/api/v1/tag": `[{"id": 1, "label": "metal"}]`,
+		"/api/v1/album": `[
+			{"title": "Master of Puppets", "artistId": 1, "monitored": true},
+			{"title": "St. Anger", "artistId": 1, "monitored": false},
+			{"title": "Blue", "artistId": 2, "monitored": true},
+			{"title": "Unmonitored Artist", "artistId": 3, "monitored": true},
+			{"title": "Removed Artist", "artistId": 9, "monitored": true}
+		]`,
+		"/api/v1/artist": `[
+			{"id": 1, "artistName": "Metallica", "monitored": true, "tags": [1]},
+			{"id": 2, "artistName": "Joni Mitchell", "monitored": true},
+			{"id": 3, "artistName": "Someone", "monitored": false}
+		]`,
+	})
+
+	tests := []struct {
+		name        string
+		cfg         domain.ArrConfig
+		wantTitles  []string
+		wantArtists []string
+	}{
+		{
+			name:        "monitored",
+			wantTitles:  []string{"Master of Puppets", "Blue"},
+			wantArtists: []string{"Metallica", "Joni Mitchell"},
+		},
+		{
+			name:        "unmonitored",
+			cfg:         domain.ArrConfig{IncludeUnmonitored: true},
+			wantTitles:  []string{"Master of Puppets", "St. Anger", "Blue", "Unmonitored Artist"},
+			wantArtists: []string{"Metallica", "Joni Mitchell", "Someone"},
+		},
+		{
+			name:        "tags",
+			cfg:         domain.ArrConfig{TagsInclude: []string{"metal"}},
+			wantTitles:  []string{"Master of Puppets"},
+			wantArtists: []string{"Metallica"},
+		},
+		{
+			name:        "excluded tags",
+			cfg:         domain.ArrConfig{TagsExclude: []string{"metal"}},
+			wantTitles:  []string{"Blue"},
+			wantArtists: []string{"Joni Mitchell"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Name, cfg.Type, cfg.Host, cfg.Apikey = "lidarr", domain.ArrTypeLidarr, ts.URL, "key"
+
+			items, err := newArrSource(&cfg).Fetch(context.Background(), &log.Logger)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTitles, items.Titles)
+			assert.Equal(t, tt.wantArtists, items.Artists)
+		})
+	}
+}
+
+func TestLidarr_Fetch_options(t *testing.T) {
+	date := func(d time.Duration) string {
+		return time.Now().Add(d).UTC().Format(time.RFC3339)
+	}
+
+	ts := newArrServer(t, map[string]string{
+		"/api/v1/album": fmt.Sprintf(`[
+			{"title": "New Album", "artistId": 1, "monitored": true, "albumType": "Album", "releaseDate": %q},
+			{"title": "Old Album", "artistId": 1, "monitored": true, "albumType": "Album", "releaseDate": %q},
+			{"title": "Upcoming EP", "artistId": 1, "monitored": true, "albumType": "EP", "releaseDate": %q},
+			{"title": "Live Album", "artistId": 1, "monitored": true, "albumType": "Album", "secondaryTypes": ["Live"]},
+			{"title": "Summer Hits", "artistId": 2, "monitored": true, "albumType": "Album", "secondaryTypes": ["Compilation"]},
+			{"title": "Single (feat. Guest)", "artistId": 1, "monitored": true, "albumType": "Single"},
+			{"title": "A Feat of Clay", "artistId": 1, "monitored": true, "albumType": "Album"},
+			{"title": "Heroic Feat Of Arms", "artistId": 1, "monitored": true, "albumType": "Album"},
+			{"title": "Little Feat Live", "artistId": 5, "monitored": true, "albumType": "Album"}
+		]`, date(-24*time.Hour), date(-5*365*24*time.Hour), date(30*24*time.Hour)),
+		"/api/v1/artist": `[
+			{"id": 1, "artistName": "Artist", "monitored": true},
+			{"id": 2, "artistName": "Various Artists", "foreignArtistId": "89ad4ac3-39f7-470e-963a-56509c546377", "monitored": true},
+			{"id": 3, "artistName": "Future Artist", "monitored": true},
+			{"id": 4, "artistName": "Duo ft. Guest", "monitored": true},
+			{"id": 5, "artistName": "Little Feat", "monitored": true}
+		]`,
+	})
+
+	tests := []struct {
+		name        string
+		cfg         domain.ArrConfig
+		wantTitles  []string
+		wantArtists []string
+	}{
+		{
+			name:        "defaults",
+			wantTitles:  []string{"New Album", "Old Album", "Upcoming EP", "Live Album", "Summer Hits", "Single (feat. Guest)", "A Feat of Clay", "Heroic Feat Of Arms", "Little Feat Live"},
+			wantArtists: []string{"Artist", "Various Artists", "Little Feat"},
+		},
+		{
+			name:        "artists of the selected albums",
+			cfg:         domain.ArrConfig{AlbumTypes: []domain.AlbumType{"EP"}},
+			wantTitles:  []string{"Upcoming EP"},
+			wantArtists: []string{"Artist"},
+		},
+		{
+			name:        "artists only",
+			cfg:         domain.ArrConfig{Emit: []domain.ArrEmit{domain.ArrEmitArtists}},
+			wantArtists: []string{"Artist", "Various Artists", "Future Artist", "Duo ft. Guest", "Little Feat"},
+		},
+		{
+			name:       "albums only",
+			cfg:        domain.ArrConfig{Emit: []domain.ArrEmit{domain.ArrEmitAlbums}, AlbumTypes: []domain.AlbumType{"Album", "EP"}},
+			wantTitles: []string{"New Album", "Old Album", "Upcoming EP", "A Feat of Clay", "Heroic Feat Of Arms", "Little Feat Live"},
+		},
+		{
+			name:       "album types with secondary types",
+			cfg:        domain.ArrConfig{Emit: []domain.ArrEmit{domain.ArrEmitAlbums}, AlbumTypes: []domain.AlbumType{"Album", "Live"}},
+			wantTitles: []string{"New Album", "Old Album", "Live Album", "A Feat of Clay", "Heroic Feat Of Arms", "Little Feat Live"},
+		},
+		{
+			name:       "released within",
+			cfg:        domain.ArrConfig{Emit: []domain.ArrEmit{domain.ArrEmitAlbums}, ReleasedWithin: 90 * 24 * time.Hour},
+			wantTitles: []string{"New Album", "Upcoming EP", "Live Album", "Summer Hits", "Single (feat. Guest)", "A Feat of Clay", "Heroic Feat Of Arms", "Little Feat Live"},
+		},
+		{
+			name:        "normalize",
+			cfg:         domain.ArrConfig{VariousArtists: domain.CreditModeNormalize, FeaturedArtists: domain.CreditModeNormalize},
+			wantTitles:  []string{"New Album", "Old Album", "Upcoming EP", "Live Album", "Summer Hits", "Single", "A Feat of Clay", "Heroic Feat Of Arms", "Little Feat Live"},
+			wantArtists: []string{"Artist", "VA", "Little Feat"},
+		},
+		{
+			name:        "normalize artists only",
+			cfg:         domain.ArrConfig{Emit: []domain.ArrEmit{domain.ArrEmitArtists}, VariousArtists: domain.CreditModeNormalize, FeaturedArtists: domain.CreditModeNormalize},
+			wantArtists: []string{"Artist", "VA", "Future Artist", "Duo", "Little Feat"},
+		},
+		{
+			name:        "skip",
+			cfg:         domain.ArrConfig{VariousArtists: domain.CreditModeSkip, FeaturedArtists: domain.CreditModeSkip},
+			wantTitles:  []string{"New Album", "Old Album", "Upcoming EP", "Live Album", "A Feat of Clay", "Heroic Feat Of Arms", "Little Feat Live"},
+			wantArtists: []string{"Artist", "Little Feat"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Name, cfg.Type, cfg.Host, cfg.Apikey = "lidarr", domain.ArrTypeLidarr, ts.URL, "key"
+
+			items, err := newArrSource(&cfg).Fetch(context.Background(), &log.Logger)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTitles, items.Titles)
+			assert.Equal(t, tt.wantArtists, items.Artists)
+		})
+	}
+}
@@ -8,10 +8,12 @@ import (
 	"testing"
 
 	"github.com/autobrr/omegabrr/internal/domain"
-	"github.com/autobrr/omegabrr/pkg/autobrr"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
 )
 
-// Unit test for the `mdblist` function with mocked dependencies.
+// Unit test for the `mdblist` source with mocked dependencies.
 func TestMDBList(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Return sample JSON response for testing
@@ -21,16 +23,40 @@ func TestMDBList(t *testing.T) {
 
 	cfg := &domain.ListConfig{
 		Name: "test",
+		Type: domain.ListTypeMdblist,
 		//URL:  "https://mdblist.com/lists/linaspurinis/top-watched-movies-of-the-week/json",
 		URL: ts.URL,
 	}
 
-	brr := &autobrr.Client{}
-
-	service := NewService(nil)
+	src := newListSource(cfg, ts.Client())
 
-	err := service.mdblist(context.Background(), cfg, false, brr)
+	items, err := src.Fetch(context.Background(), &log.Logger)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
+
+	assert.Equal(t, []string{"Movie 1", "Movie 2"}, items.Titles)
+}
+
+func TestMDBList_basicAuth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "user" || pass != "pass" {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintln(w, `[{"title": "Movie 1"}]`)
+	}))
+	defer ts.Close()
+
+	cfg := &domain.ListConfig{
+		Name:      "test",
+		Type:      domain.ListTypeMdblist,
+		URL:       ts.URL,
+		BasicAuth: &domain.BasicAuth{User: "user", Pass: "pass"},
+	}
+
+	items, err := newListSource(cfg, ts.Client()).Fetch(context.Background(), &log.Logger)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Movie 1"}, items.Titles)
 }
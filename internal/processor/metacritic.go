@@ -8,58 +8,32 @@ import (
 	"strings"
 
 	"github.com/autobrr/omegabrr/internal/domain"
-	"github.com/autobrr/omegabrr/pkg/autobrr"
-	"github.com/fatih/color"
-	"github.com/pkg/errors"
-	"github.com/rs/zerolog/log"
-)
-
-func (s Service) metacritic(ctx context.Context, cfg *domain.ListConfig, dryRun bool, brr *autobrr.Client) error {
-	l := log.With().Str("type", "metacritic").Str("client", cfg.Name).Logger()
 
-	if cfg.URL == "" {
-		errMsg := "no URL provided for Metacritic list"
-		l.Error().Msg(errMsg)
-		return fmt.Errorf(errMsg)
-	}
-
-	green := color.New(color.FgGreen).SprintFunc()
-	l.Debug().Msgf("fetching titles from %s", green(cfg.URL))
+	"github.com/rs/zerolog"
+)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.URL, nil)
-	if err != nil {
-		l.Error().Err(err).Msg("could not make new request")
-		return err
-	}
+func init() {
+	RegisterListSource(domain.ListTypeMetacritic, newMetacriticSource)
+}
 
-	for k, v := range cfg.Headers {
-		req.Header.Set(k, v)
-	}
+type metacriticSource struct {
+	listSource
+}
 
-	setUserAgent(req)
+func newMetacriticSource(cfg *domain.ListConfig, client *http.Client) Source {
+	return &metacriticSource{listSource{cfg: cfg, client: client, field: FieldAlbums}}
+}
 
-	resp, err := s.httpClient.Do(req)
+func (s *metacriticSource) Fetch(ctx context.Context, logger *zerolog.Logger) (*Items, error) {
+	resp, err := s.get(ctx, logger, nil)
 	if err != nil {
-		l.Error().Err(err).Msgf("failed to fetch titles from URL: %s", cfg.URL)
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
-		errMsg := fmt.Sprintf("No endpoint found at %v. (404 Not Found)", cfg.URL)
-		l.Error().Msg(errMsg)
-		return fmt.Errorf(errMsg)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		l.Error().Msgf("failed to fetch titles from URL: %s", cfg.URL)
-		return fmt.Errorf("failed to fetch titles from URL: %s", cfg.URL)
-	}
-
 	contentType := resp.Header.Get("Content-Type")
 	if !strings.HasPrefix(contentType, "application/json") {
-		errMsg := fmt.Sprintf("invalid content type for URL: %s, content type should be application/json", cfg.URL)
-		return fmt.Errorf(errMsg)
+		return nil, fmt.Errorf("invalid content type for URL: %s, content type should be application/json", s.cfg.URL)
 	}
 
 	var data struct {
@@ -71,66 +45,22 @@ func (s Service) metacritic(ctx context.Context, cfg *domain.ListConfig, dryRun
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		l.Error().Err(err).Msgf("failed to decode JSON data from URL: %s", cfg.URL)
-		return err
+		logger.Error().Err(err).Msgf("failed to decode JSON data from URL: %s", s.cfg.URL)
+		return nil, err
 	}
 
 	var titles []string
 	var artists []string
+	seenArtists := map[string]struct{}{}
 
 	for _, album := range data.Albums {
 		titles = append(titles, album.Title)
-		artists = append(artists, album.Artist)
-	}
 
-	// Deduplicate artists
-	uniqueArtists := []string{}
-	seenArtists := map[string]struct{}{}
-	for _, artist := range artists {
-		if _, ok := seenArtists[artist]; !ok {
-			uniqueArtists = append(uniqueArtists, artist)
-			seenArtists[artist] = struct{}{}
-		}
-	}
-
-	for _, filterID := range cfg.Filters {
-		l.Debug().Msgf("updating filter: %v", filterID)
-
-		filterTitles := []string{}
-		for _, title := range titles {
-			filterTitles = append(filterTitles, processTitle(title, cfg.MatchRelease)...)
+		if _, ok := seenArtists[album.Artist]; !ok {
+			artists = append(artists, album.Artist)
+			seenArtists[album.Artist] = struct{}{}
 		}
-
-		filterArtists := []string{}
-		for _, artist := range uniqueArtists {
-			filterArtists = append(filterArtists, processTitle(artist, cfg.MatchRelease)...)
-		}
-
-		joinedArtists := strings.Join(filterArtists, ",")
-		joinedTitles := strings.Join(filterTitles, ",")
-
-		l.Trace().Msgf("%s", joinedTitles)
-
-		if len(joinedTitles) == 0 {
-			l.Debug().Msgf("no titles found for filter: %v", filterID)
-			return nil
-		}
-
-		f := autobrr.UpdateFilter{Albums: joinedTitles, Artists: joinedArtists}
-
-		if cfg.MatchRelease {
-			f = autobrr.UpdateFilter{MatchReleases: joinedTitles}
-		}
-
-		if !dryRun {
-			if err := brr.UpdateFilterByID(ctx, filterID, f); err != nil {
-				l.Error().Err(err).Msgf("error updating filter: %v", filterID)
-				return errors.Wrapf(err, "error updating filter: %v", filterID)
-			}
-		}
-
-		l.Debug().Msgf("successfully updated filter: %v", filterID)
 	}
 
-	return nil
+	return &Items{Titles: titles, Artists: artists}, nil
 }
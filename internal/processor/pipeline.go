@@ -0,0 +1,668 @@
+package processor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+	"github.com/autobrr/omegabrr/pkg/autobrr"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// sourceResult is what a single source contributed to a run.
+type sourceResult struct {
+	info     SourceInfo
+	items    *Items
+	patterns map[Field][]string
+	err      error
+	class    domain.ErrorClass
+	// skipped is set for disabled sources, which are not fetched.
+	skipped bool
+}
+
+// filterUpdate collects the titles and patterns every source contributes to one autobrr filter.
+type filterUpdate struct {
+	id       int
+	sources  []string
+	failed   []string
+	disabled []string
+	titles   map[string]struct{}
+	patterns map[Field]map[string]struct{}
+
+	// failedClass is the error class of the failed sources, used when the filter is skipped.
+	failedClass domain.ErrorClass
+}
+
+// RunOptions selects what a run processes and records why it was started.
+type RunOptions struct {
+	// ID continues a run reserved with Queue. A new ID is used when empty.
+	ID      string
+	Trigger domain.RunTrigger
+	// Kind limits the run to arrs or lists. Everything is processed when empty.
+	Kind SourceKind
+	// Sources limits the run to the arrs, lists and sets with these names.
+	Sources []string
+	DryRun  bool
+	// MergedTriggers are the triggers of other requests that were merged into
+	// this run, besides Trigger.
+	MergedTriggers []domain.RunTrigger
+}
+
+// matches reports whether the options select the source on their own, before
+// the sources it needs are added.
+func (o RunOptions) matches(info SourceInfo) bool {
+	if o.Kind != "" && info.Kind != o.Kind {
+		return false
+	}
+
+	if len(o.Sources) > 0 {
+		for _, name := range o.Sources {
+			if name == info.Name {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// Run gathers titles from all sources first, then merges them per target filter
+// and sends a single update per filter. A filter is left untouched if any of
+// the sources writing to it failed, so it is never rebuilt from a partial set.
+//
+// Sources are fetched by a bounded pool of workers. Once the run timeout passes,
+// sources that haven't finished are cancelled, but the filters of those that did
+// are still updated.
+func (s Service) Run(ctx context.Context, opts RunOptions) *domain.RunReport {
+	s = s.latest()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	run := s.newRun(opts, cancel)
+	s.execute(ctx, run, opts)
+
+	return run
+}
+
+// Queue reserves a run that is started later with Run, so it can be followed
+// with GetRun and Subscribe, or cancelled, before it starts. Its status is
+// queued until then.
+func (s Service) Queue(opts RunOptions) string {
+	run := &domain.RunReport{
+		ID:      newRunID(time.Now()),
+		Trigger: opts.Trigger,
+		Status:  domain.RunStatusQueued,
+		DryRun:  opts.DryRun,
+	}
+
+	if s.runs != nil {
+		s.runs.queue(run)
+	}
+
+	return run.ID
+}
+
+// GetRun returns a run that is in progress or finished recently.
+func (s Service) GetRun(id string) (*domain.RunReport, bool) {
+	if s.runs == nil {
+		return nil, false
+	}
+
+	return s.runs.get(id)
+}
+
+// Subscribe returns the events of a run in progress, and a function to stop
+// receiving them. The channel is closed when the run finishes.
+func (s Service) Subscribe(id string) (<-chan Event, func(), bool) {
+	if s.runs == nil {
+		return nil, nil, false
+	}
+
+	return s.runs.subscribe(id)
+}
+
+// Cancel stops a run in progress. Sources that are still being fetched are
+// cancelled and no more filters are updated. A queued run is cancelled as soon
+// as it starts. It returns false if the run is not queued or in progress.
+func (s Service) Cancel(id string) bool {
+	if s.runs == nil {
+		return false
+	}
+
+	return s.runs.cancel(id)
+}
+
+func (s Service) newRun(opts RunOptions, cancel context.CancelFunc) *domain.RunReport {
+	id := opts.ID
+	if id == "" {
+		id = newRunID(time.Now())
+	}
+
+	run := &domain.RunReport{
+		ID:             id,
+		Trigger:        opts.Trigger,
+		MergedTriggers: opts.MergedTriggers,
+		Status:         domain.RunStatusRunning,
+		DryRun:         opts.DryRun,
+		StartedAt:      time.Now(),
+	}
+
+	if s.runs != nil {
+		s.runs.start(run, cancel)
+	}
+
+	return run
+}
+
+func (s Service) execute(ctx context.Context, run *domain.RunReport, opts RunOptions) {
+	var events emitter
+	if s.runs != nil {
+		events = func(ev Event) {
+			ev.RunID = run.ID
+			ev.Time = time.Now()
+			s.runs.publish(ev)
+		}
+	}
+
+	sources := s.sources()
+	if opts.Kind != "" || len(opts.Sources) > 0 {
+		sources = selectSources(sources, opts.matches)
+	}
+
+	l := log.With().Str("run", run.ID).Logger()
+	l.Debug().Msgf("starting %s run with %d sources", run.Trigger, len(sources))
+
+	events.emit(Event{Type: EventRunStarted})
+
+	results := s.fetchAll(ctx, sources, events)
+	for _, res := range results {
+		run.Sources = append(run.Sources, newSourceReport(res))
+	}
+
+	for _, update := range groupByFilter(results) {
+		fr := s.update(ctx, run.ID, update, opts.DryRun)
+		run.Filters = append(run.Filters, fr)
+
+		events.emit(Event{Type: EventFilterUpdated, Filter: &fr})
+	}
+
+	run.FinishedAt = time.Now()
+	run.Duration = run.FinishedAt.Sub(run.StartedAt)
+	run.Status = run.Result()
+
+	if s.store != nil {
+		if err := s.store.SaveRun(run); err != nil {
+			l.Error().Err(err).Msg("could not save run to history")
+		}
+	}
+
+	events.emit(Event{Type: EventRunFinished, Run: run})
+
+	if s.runs != nil {
+		s.runs.finish(run)
+	}
+
+	l.Debug().Msgf("finished %s run in %s", run.Trigger, run.Duration)
+}
+
+func newSourceReport(res *sourceResult) domain.SourceReport {
+	sr := domain.SourceReport{
+		Name:   res.info.Name,
+		Type:   res.info.Type,
+		Kind:   string(res.info.Kind),
+		Status: domain.SourceStatusOK,
+	}
+
+	if res.items != nil {
+		sr.ItemsFetched = len(res.items.Titles) + len(res.items.Artists)
+	}
+	sr.TitlesGenerated = countPatterns(res.patterns)
+
+	if res.skipped {
+		sr.Status = domain.SourceStatusSkipped
+	}
+
+	if res.err != nil {
+		sr.Status = domain.SourceStatusFailed
+		sr.Error = &domain.RunError{Class: res.class, Message: res.err.Error()}
+	}
+
+	return sr
+}
+
+// newRunID returns an ID that sorts by the time the run started.
+func newRunID(t time.Time) string {
+	b := make([]byte, 3)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%s", t.UTC().Format("20060102-150405"), hex.EncodeToString(b))
+}
+
+// fetchAll fetches the sources concurrently and returns their results in the same order.
+func (s Service) fetchAll(ctx context.Context, sources []Source, events emitter) []*sourceResult {
+	opts := s.cfg.Processing
+
+	runCtx := ctx
+	if opts.RunTimeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, opts.RunTimeout)
+		defer cancel()
+	}
+
+	workers := opts.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(sources) {
+		workers = len(sources)
+	}
+
+	results := make([]*sourceResult, len(sources))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				info := sources[i].Info()
+
+				if info.Disabled {
+					results[i] = &sourceResult{info: info, skipped: true}
+					sr := newSourceReport(results[i])
+					events.emit(Event{Type: EventSourceFinished, Source: &sr})
+					continue
+				}
+
+				events.emit(Event{Type: EventSourceStarted, Source: &domain.SourceReport{
+					Name:   info.Name,
+					Type:   info.Type,
+					Kind:   string(info.Kind),
+					Status: domain.SourceStatusRunning,
+				}})
+
+				results[i] = s.fetchWithTimeout(runCtx, sources[i], opts.SourceTimeout)
+
+				sr := newSourceReport(results[i])
+				events.emit(Event{Type: EventSourceFinished, Source: &sr})
+			}
+		}()
+	}
+
+	for i := range sources {
+		jobs <- i
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	return results
+}
+
+// fetchWithTimeout fetches a single source within its own timeout and explains
+// which deadline was hit if it was cancelled.
+func (s Service) fetchWithTimeout(runCtx context.Context, src Source, timeout time.Duration) *sourceResult {
+	if err := runCtx.Err(); err != nil {
+		return &sourceResult{
+			info:  src.Info(),
+			err:   errors.Wrap(err, "run cancelled before source started"),
+			class: contextErrorClass(err),
+		}
+	}
+
+	ctx := runCtx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(runCtx, timeout)
+		defer cancel()
+	}
+
+	res := s.fetch(ctx, src)
+
+	if res.err != nil {
+		switch {
+		case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+			res.err = errors.Wrap(res.err, "run timed out")
+			res.class = domain.ErrorClassTimeout
+		case runCtx.Err() != nil:
+			res.err = errors.Wrap(res.err, "run cancelled")
+			res.class = domain.ErrorClassCancelled
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			res.err = errors.Wrapf(res.err, "source timed out after %s", timeout)
+			res.class = domain.ErrorClassTimeout
+		}
+	}
+
+	return res
+}
+
+func contextErrorClass(err error) domain.ErrorClass {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return domain.ErrorClassTimeout
+	}
+	return domain.ErrorClassCancelled
+}
+
+// fetch validates a source, fetches its items and turns them into filter patterns.
+func (s Service) fetch(ctx context.Context, src Source) *sourceResult {
+	info := src.Info()
+	res := &sourceResult{info: info}
+
+	l := log.With().Str("type", info.Type).Str("client", info.Name).Logger()
+
+	if err := src.Validate(); err != nil {
+		l.Error().Err(err).Msg("invalid configuration")
+		res.err = err
+		res.class = domain.ErrorClassConfig
+		return res
+	}
+
+	l.Debug().Msgf("gathering titles...")
+
+	items, err := src.Fetch(ctx, &l)
+	if err != nil {
+		l.Error().Err(err).Msgf("error while processing %s, continuing with other sources", info.Type)
+		res.err = err
+		res.class = domain.ErrorClassFetch
+		return res
+	}
+
+	res.items = items
+	res.patterns = buildPatterns(info, items)
+
+	l.Debug().Msgf("got %v filter titles", countPatterns(res.patterns))
+	l.Trace().Msgf("%v", res.patterns)
+
+	return res
+}
+
+// groupByFilter merges the patterns of all sources per filter ID, ordered by ID.
+func groupByFilter(results []*sourceResult) []*filterUpdate {
+	updates := make(map[int]*filterUpdate)
+
+	for _, res := range results {
+		for _, filterID := range res.info.Filters {
+			update, ok := updates[filterID]
+			if !ok {
+				update = &filterUpdate{
+					id:       filterID,
+					titles:   make(map[string]struct{}),
+					patterns: make(map[Field]map[string]struct{}),
+				}
+				updates[filterID] = update
+			}
+
+			// disabled sources don't contribute, their titles drop out of the filter
+			if res.skipped {
+				update.disabled = append(update.disabled, res.info.Name)
+				continue
+			}
+
+			update.sources = append(update.sources, res.info.Name)
+
+			if res.err != nil {
+				update.failed = append(update.failed, res.info.Name)
+				update.failedClass = worseClass(update.failedClass, res.class)
+				continue
+			}
+
+			for _, title := range res.items.Titles {
+				update.titles[title] = struct{}{}
+			}
+			for _, artist := range res.items.Artists {
+				update.titles[artist] = struct{}{}
+			}
+
+			for field, patterns := range res.patterns {
+				set, ok := update.patterns[field]
+				if !ok {
+					set = make(map[string]struct{}, len(patterns))
+					update.patterns[field] = set
+				}
+				for _, p := range patterns {
+					set[p] = struct{}{}
+				}
+			}
+		}
+	}
+
+	ordered := make([]*filterUpdate, 0, len(updates))
+	for _, update := range updates {
+		ordered = append(ordered, update)
+	}
+
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].id < ordered[j].id })
+
+	return ordered
+}
+
+// update sends the merged patterns of one filter to autobrr and records the titles
+// that were added or removed since the last time it was written.
+func (s Service) update(ctx context.Context, runID string, update *filterUpdate, dryRun bool) domain.FilterReport {
+	l := log.With().Int("filter", update.id).Strs("sources", update.sources).Logger()
+
+	fr := domain.FilterReport{
+		ID:      update.id,
+		Sources: update.sources,
+	}
+
+	if len(update.sources) == 0 && len(update.disabled) > 0 {
+		l.Debug().Strs("disabled", update.disabled).Msgf("all sources disabled, skipping filter update: %v", update.id)
+		fr.Sources = update.disabled
+		fr.Status = domain.FilterStatusSkipped
+		return fr
+	}
+
+	if err := ctx.Err(); err != nil {
+		l.Warn().Msgf("run stopped, skipping filter update: %v", update.id)
+		fr.Status = domain.FilterStatusSkipped
+		fr.Error = &domain.RunError{Class: contextErrorClass(err), Message: "run stopped before filter update"}
+		return fr
+	}
+
+	if len(update.failed) > 0 {
+		l.Warn().Strs("failed", update.failed).Msgf("skipping filter update: %v", update.id)
+		fr.Status = domain.FilterStatusSkipped
+		fr.Error = &domain.RunError{
+			Class:   update.failedClass,
+			Message: fmt.Sprintf("skipped, sources failed: %s", strings.Join(update.failed, ", ")),
+		}
+		return fr
+	}
+
+	patterns := make(map[Field][]string, len(update.patterns))
+	for field, set := range update.patterns {
+		patterns[field] = sortedKeys(set)
+	}
+
+	if countPatterns(patterns) == 0 {
+		l.Debug().Msgf("no titles found for filter: %v", update.id)
+		fr.Status = domain.FilterStatusEmpty
+		return fr
+	}
+
+	f := newUpdateFilter(patterns)
+	titles := sortedKeys(update.titles)
+	fr.Titles = countPatterns(patterns)
+
+	if s.store != nil {
+		previous, err := s.store.FilterState(update.id)
+		if err != nil {
+			l.Warn().Err(err).Msgf("could not load previous titles for filter: %v", update.id)
+		} else if previous != nil {
+			fr.Added, fr.Removed = diffTitles(previous.Titles, titles)
+		}
+	}
+
+	if dryRun {
+		l.Debug().Msgf("dry-run, skipping update of filter: %v", update.id)
+		fr.Status = domain.FilterStatusDryRun
+		return fr
+	}
+
+	if s.cfg.Processing.SkipUnchanged {
+		unchanged, err := s.unchanged(ctx, update.id, f)
+		if err != nil {
+			l.Warn().Err(err).Msgf("could not check filter for changes, updating anyway: %v", update.id)
+		} else if unchanged {
+			l.Debug().Msgf("filter unchanged, skipping update: %v", update.id)
+			fr.Status = domain.FilterStatusUnchanged
+			return fr
+		}
+	}
+
+	l.Debug().Msgf("updating filter: %v", update.id)
+
+	if err := s.autobrrClient.UpdateFilterByID(ctx, update.id, f); err != nil {
+		l.Error().Err(err).Msgf("error updating filter: %v", update.id)
+		fr.Status = domain.FilterStatusFailed
+		fr.Error = &domain.RunError{
+			Class:   domain.ErrorClassUpdate,
+			Message: errors.Wrapf(err, "error updating filter: %v", update.id).Error(),
+		}
+		return fr
+	}
+
+	fp := fingerprint(f)
+	if s.fingerprints != nil {
+		s.fingerprints.set(update.id, fp)
+	}
+
+	if s.store != nil {
+		state := &domain.FilterState{
+			ID:          update.id,
+			Titles:      titles,
+			Fingerprint: fp,
+			RunID:       runID,
+			UpdatedAt:   time.Now(),
+		}
+		if err := s.store.SaveFilterState(state); err != nil {
+			l.Warn().Err(err).Msgf("could not save titles for filter: %v", update.id)
+		}
+	}
+
+	l.Debug().Msgf("successfully updated filter: %v (%d added, %d removed)", update.id, len(fr.Added), len(fr.Removed))
+	fr.Status = domain.FilterStatusUpdated
+
+	return fr
+}
+
+// worseClass returns the class that should be reported when a filter has sources
+// failing for different reasons. Timeouts and cancellations win over the rest,
+// since they usually explain the other failures.
+func worseClass(a, b domain.ErrorClass) domain.ErrorClass {
+	rank := func(c domain.ErrorClass) int {
+		switch c {
+		case domain.ErrorClassCancelled:
+			return 4
+		case domain.ErrorClassTimeout:
+			return 3
+		case domain.ErrorClassFetch:
+			return 2
+		case domain.ErrorClassConfig:
+			return 1
+		}
+		return 0
+	}
+
+	if rank(b) > rank(a) {
+		return b
+	}
+	return a
+}
+
+// diffTitles returns the titles only in current and the titles only in previous.
+func diffTitles(previous, current []string) (added, removed []string) {
+	prev := make(map[string]struct{}, len(previous))
+	for _, t := range previous {
+		prev[t] = struct{}{}
+	}
+
+	cur := make(map[string]struct{}, len(current))
+	for _, t := range current {
+		cur[t] = struct{}{}
+		if _, ok := prev[t]; !ok {
+			added = append(added, t)
+		}
+	}
+
+	for _, t := range previous {
+		if _, ok := cur[t]; !ok {
+			removed = append(removed, t)
+		}
+	}
+
+	return added, removed
+}
+
+// buildPatterns turns raw items into filter patterns and places them in the fields the source targets.
+func buildPatterns(info SourceInfo, items *Items) map[Field][]string {
+	patterns := make(map[Field][]string)
+
+	titles := processTitles(items.Titles, info.MatchRelease)
+
+	if info.MatchRelease {
+		patterns[FieldMatchReleases] = titles
+		return patterns
+	}
+
+	field := info.Field
+	if field == "" {
+		field = FieldShows
+	}
+
+	patterns[field] = titles
+	patterns[FieldArtists] = processTitles(items.Artists, info.MatchRelease)
+
+	return patterns
+}
+
+func newUpdateFilter(patterns map[Field][]string) autobrr.UpdateFilter {
+	return autobrr.UpdateFilter{
+		Shows:         strings.Join(patterns[FieldShows], ","),
+		Albums:        strings.Join(patterns[FieldAlbums], ","),
+		Artists:       strings.Join(patterns[FieldArtists], ","),
+		MatchReleases: strings.Join(patterns[FieldMatchReleases], ","),
+	}
+}
+
+// processTitles expands every title into its filter patterns and returns them deduplicated and sorted.
+func processTitles(titles []string, matchRelease bool) []string {
+	set := make(map[string]struct{})
+	for _, title := range titles {
+		for _, t := range processTitle(title, matchRelease) {
+			set[t] = struct{}{}
+		}
+	}
+
+	return sortedKeys(set)
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func countPatterns(patterns map[Field][]string) int {
+	var n int
+	for _, p := range patterns {
+		n += len(p)
+	}
+	return n
+}
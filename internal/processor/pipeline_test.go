@@ -0,0 +1,216 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+	"github.com/autobrr/omegabrr/pkg/autobrr"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSource returns its titles after delay, or gives up when the context is done.
+type fakeSource struct {
+	info   SourceInfo
+	titles []string
+	delay  time.Duration
+}
+
+func (s *fakeSource) Info() SourceInfo { return s.info }
+
+func (s *fakeSource) Validate() error { return nil }
+
+func (s *fakeSource) Fetch(ctx context.Context, _ *zerolog.Logger) (*Items, error) {
+	select {
+	case <-time.After(s.delay):
+		return &Items{Titles: s.titles}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func Test_buildPatterns(t *testing.T) {
+	tests := []struct {
+		name  string
+		info  SourceInfo
+		items *Items
+		want  autobrr.UpdateFilter
+	}{
+		{
+			name:  "shows",
+			info:  SourceInfo{Field: FieldShows},
+			items: &Items{Titles: []string{"The Matrix", "Dune"}},
+			want:  autobrr.UpdateFilter{Shows: "Dune,The?Matrix"},
+		},
+		{
+			name:  "match_release",
+			info:  SourceInfo{Field: FieldShows, MatchRelease: true},
+			items: &Items{Titles: []string{"Dune"}},
+			want:  autobrr.UpdateFilter{MatchReleases: "*Dune*"},
+		},
+		{
+			name:  "albums_and_artists",
+			info:  SourceInfo{Field: FieldAlbums},
+			items: &Items{Titles: []string{"Discovery"}, Artists: []string{"Daft Punk"}},
+			want:  autobrr.UpdateFilter{Albums: "Discovery", Artists: "Daft?Punk"},
+		},
+		{
+			name:  "match_release_drops_artists",
+			info:  SourceInfo{Field: FieldAlbums, MatchRelease: true},
+			items: &Items{Titles: []string{"Discovery"}, Artists: []string{"Daft Punk"}},
+			want:  autobrr.UpdateFilter{MatchReleases: "*Discovery*"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, newUpdateFilter(buildPatterns(tt.info, tt.items)))
+		})
+	}
+}
+
+func Test_groupByFilter(t *testing.T) {
+	results := []*sourceResult{
+		{
+			info:     SourceInfo{Name: "radarr", Filters: []int{2, 1}},
+			items:    &Items{Titles: []string{"Dune", "The Matrix"}},
+			patterns: map[Field][]string{FieldShows: {"Dune", "The?Matrix"}},
+		},
+		{
+			info:     SourceInfo{Name: "radarr4k", Filters: []int{1}},
+			items:    &Items{Titles: []string{"Dune", "Alien"}},
+			patterns: map[Field][]string{FieldShows: {"Dune", "Alien"}},
+		},
+		{
+			info: SourceInfo{Name: "trakt", Filters: []int{2}},
+			err:  errors.New("boom"),
+		},
+	}
+
+	updates := groupByFilter(results)
+
+	assert.Len(t, updates, 2)
+
+	assert.Equal(t, 1, updates[0].id)
+	assert.Equal(t, []string{"radarr", "radarr4k"}, updates[0].sources)
+	assert.Empty(t, updates[0].failed)
+	assert.Equal(t, []string{"Alien", "Dune", "The?Matrix"}, sortedKeys(updates[0].patterns[FieldShows]))
+	assert.Equal(t, []string{"Alien", "Dune", "The Matrix"}, sortedKeys(updates[0].titles))
+
+	assert.Equal(t, 2, updates[1].id)
+	assert.Equal(t, []string{"trakt"}, updates[1].failed)
+}
+
+func Test_selectSources(t *testing.T) {
+	all := []Source{
+		&unsupportedSource{info: SourceInfo{Name: "radarr", Kind: SourceKindArr, Filters: []int{1}}},
+		&unsupportedSource{info: SourceInfo{Name: "trakt", Kind: SourceKindList, Filters: []int{1}}},
+		&unsupportedSource{info: SourceInfo{Name: "mdblist", Kind: SourceKindList, Filters: []int{2}}},
+	}
+
+	var names []string
+	for _, src := range selectSources(all, RunOptions{Kind: SourceKindArr}.matches) {
+		names = append(names, src.Info().Name)
+	}
+
+	assert.Equal(t, []string{"radarr", "trakt"}, names)
+
+	names = nil
+	for _, src := range selectSources(all, RunOptions{Sources: []string{"mdblist"}}.matches) {
+		names = append(names, src.Info().Name)
+	}
+
+	assert.Equal(t, []string{"mdblist"}, names)
+}
+
+func TestService_fetchAll_timeouts(t *testing.T) {
+	s := Service{cfg: &domain.Config{}}
+	s.cfg.Processing.Concurrency = 2
+	s.cfg.Processing.SourceTimeout = 50 * time.Millisecond
+
+	sources := []Source{
+		&fakeSource{info: SourceInfo{Name: "fast"}, titles: []string{"Dune"}},
+		&fakeSource{info: SourceInfo{Name: "slow"}, delay: time.Second},
+	}
+
+	results := s.fetchAll(context.Background(), sources, nil)
+
+	assert.NoError(t, results[0].err)
+	assert.Equal(t, []string{"Dune"}, results[0].patterns[FieldShows])
+
+	assert.ErrorIs(t, results[1].err, context.DeadlineExceeded)
+	assert.Contains(t, results[1].err.Error(), "source timed out")
+	assert.Equal(t, domain.ErrorClassTimeout, results[1].class)
+}
+
+func TestService_fetchAll_runTimeout(t *testing.T) {
+	s := Service{cfg: &domain.Config{}}
+	s.cfg.Processing.Concurrency = 1
+	s.cfg.Processing.RunTimeout = 50 * time.Millisecond
+
+	sources := []Source{
+		&fakeSource{info: SourceInfo{Name: "slow"}, delay: time.Second},
+		&fakeSource{info: SourceInfo{Name: "queued"}},
+	}
+
+	results := s.fetchAll(context.Background(), sources, nil)
+
+	assert.Contains(t, results[0].err.Error(), "run timed out")
+	assert.Contains(t, results[1].err.Error(), "run cancelled before source started")
+	assert.Equal(t, domain.ErrorClassTimeout, results[0].class)
+	assert.Equal(t, domain.ErrorClassTimeout, results[1].class)
+}
+
+func TestService_fetchAll_disabled(t *testing.T) {
+	s := Service{cfg: &domain.Config{}}
+
+	radarr := &fakeSource{info: SourceInfo{Name: "radarr", Filters: []int{1}}, titles: []string{"Dune"}}
+	trakt := disableUnless(false, &fakeSource{info: SourceInfo{Name: "trakt", Filters: []int{1, 2}}, titles: []string{"Heat"}})
+
+	results := s.fetchAll(context.Background(), []Source{radarr, trakt}, nil)
+
+	assert.True(t, results[1].skipped)
+	assert.Nil(t, results[1].items)
+	assert.Equal(t, domain.SourceStatusSkipped, newSourceReport(results[1]).Status)
+
+	updates := groupByFilter(results)
+	assert.Equal(t, []string{"radarr"}, updates[0].sources)
+	assert.Equal(t, map[string]struct{}{"Dune": {}}, updates[0].titles)
+
+	fr := s.update(context.Background(), "run", updates[1], true)
+	assert.Equal(t, domain.FilterStatusSkipped, fr.Status)
+	assert.Equal(t, []string{"trakt"}, fr.Sources)
+	assert.Nil(t, fr.Error)
+}
+
+func Test_newSourceReport(t *testing.T) {
+	sr := newSourceReport(&sourceResult{
+		info:     SourceInfo{Name: "lidarr", Type: "lidarr", Kind: SourceKindArr},
+		items:    &Items{Titles: []string{"Discovery"}, Artists: []string{"Daft Punk"}},
+		patterns: map[Field][]string{FieldAlbums: {"Discovery"}, FieldArtists: {"Daft?Punk"}},
+	})
+
+	assert.Equal(t, domain.SourceStatusOK, sr.Status)
+	assert.Equal(t, 2, sr.ItemsFetched)
+	assert.Equal(t, 2, sr.TitlesGenerated)
+	assert.Nil(t, sr.Error)
+
+	sr = newSourceReport(&sourceResult{
+		info:  SourceInfo{Name: "radarr", Type: "radarr", Kind: SourceKindArr},
+		err:   errors.New("no host provided"),
+		class: domain.ErrorClassConfig,
+	})
+
+	assert.Equal(t, domain.SourceStatusFailed, sr.Status)
+	assert.Equal(t, &domain.RunError{Class: domain.ErrorClassConfig, Message: "no host provided"}, sr.Error)
+}
+
+func Test_diffTitles(t *testing.T) {
+	added, removed := diffTitles([]string{"Alien", "Dune"}, []string{"Dune", "Heat"})
+
+	assert.Equal(t, []string{"Heat"}, added)
+	assert.Equal(t, []string{"Alien"}, removed)
+}
@@ -8,65 +8,61 @@ import (
 	"strings"
 
 	"github.com/autobrr/omegabrr/internal/domain"
-	"github.com/autobrr/omegabrr/pkg/autobrr"
-	"github.com/pkg/errors"
 
-	"github.com/fatih/color"
-	"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog"
 )
 
-func (s Service) plaintext(ctx context.Context, cfg *domain.ListConfig, dryRun bool, brr *autobrr.Client) error {
-	l := log.With().Str("type", "plaintext").Str("client", cfg.Name).Logger()
+func init() {
+	RegisterListSource(domain.ListTypePlaintext, newPlaintextSource)
+}
+
+type plaintextSource struct {
+	listSource
+}
 
-	if cfg.URL == "" {
-		errMsg := fmt.Sprintf("no URL provided for plaintext list: %s", cfg.Name)
-		l.Error().Msg(errMsg)
-		return fmt.Errorf(errMsg)
+// Plaintext lists can be anything, so they write to Albums when album is set and Shows otherwise.
+func newPlaintextSource(cfg *domain.ListConfig, client *http.Client) Source {
+	field := FieldShows
+	if cfg.Album {
+		field = FieldAlbums
 	}
 
-	var titles []string
+	return &plaintextSource{listSource{cfg: cfg, client: client, field: field}}
+}
 
-	green := color.New(color.FgGreen).SprintFunc()
-	l.Debug().Msgf("fetching titles from %s", green(cfg.URL))
+func (s *plaintextSource) Info() SourceInfo {
+	info := s.listSource.Info()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.URL, nil)
-	if err != nil {
-		l.Error().Err(err).Msg("could not make new request")
-		return err
+	// album takes precedence over matchRelease
+	if s.cfg.Album {
+		info.Field = FieldAlbums
+		info.MatchRelease = false
 	}
 
-	for k, v := range cfg.Headers {
-		req.Header.Set(k, v)
-	}
-
-	setUserAgent(req)
+	return info
+}
 
-	resp, err := s.httpClient.Do(req)
+func (s *plaintextSource) Fetch(ctx context.Context, logger *zerolog.Logger) (*Items, error) {
+	resp, err := s.get(ctx, logger, nil)
 	if err != nil {
-		l.Error().Err(err).Msgf("failed to fetch titles from URL: %s", cfg.URL)
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		l.Error().Msgf("failed to fetch titles from URL: %s", cfg.URL)
-		return fmt.Errorf("failed to fetch titles from URL: %s", cfg.URL)
-	}
-
 	contentType := resp.Header.Get("Content-Type")
 	if !strings.HasPrefix(contentType, "text/plain") {
-		l.Error().Msgf("failed to fetch plaintext from URL: %s", cfg.URL)
-		return fmt.Errorf("failed to fetch plaintext from URL: %s", cfg.URL)
+		logger.Error().Msgf("failed to fetch plaintext from URL: %s", s.cfg.URL)
+		return nil, fmt.Errorf("failed to fetch plaintext from URL: %s", s.cfg.URL)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		l.Error().Err(err).Msgf("failed to read response body from URL: %s", cfg.URL)
-		return err
+		logger.Error().Err(err).Msgf("failed to read response body from URL: %s", s.cfg.URL)
+		return nil, err
 	}
 
-	titleLines := strings.Split(string(body), "\n")
-	for _, titleLine := range titleLines {
+	var titles []string
+	for _, titleLine := range strings.Split(string(body), "\n") {
 		title := strings.TrimSpace(titleLine)
 		if title == "" {
 			continue
@@ -74,40 +70,5 @@ func (s Service) plaintext(ctx context.Context, cfg *domain.ListConfig, dryRun b
 		titles = append(titles, title)
 	}
 
-	for _, filterID := range cfg.Filters {
-		l.Debug().Msgf("updating filter: %v", filterID)
-
-		filterTitles := []string{}
-		for _, title := range titles {
-			filterTitles = append(filterTitles, processTitle(title, cfg.MatchRelease)...)
-		}
-
-		joinedTitles := strings.Join(filterTitles, ",")
-
-		l.Trace().Msgf("%s", joinedTitles)
-
-		if len(joinedTitles) == 0 {
-			l.Debug().Msgf("no titles found for filter: %v", filterID)
-			return nil
-		}
-
-		f := autobrr.UpdateFilter{Shows: joinedTitles}
-
-		if cfg.Album {
-			f = autobrr.UpdateFilter{Albums: joinedTitles}
-		} else if cfg.MatchRelease {
-			f = autobrr.UpdateFilter{MatchReleases: joinedTitles}
-		}
-
-		if !dryRun {
-			if err := brr.UpdateFilterByID(ctx, filterID, f); err != nil {
-				l.Error().Err(err).Msgf("error updating filter: %v", filterID)
-				return errors.Wrapf(err, "error updating filter: %v", filterID)
-			}
-		}
-
-		l.Debug().Msgf("successfully updated filter: %v", filterID)
-	}
-
-	return nil
+	return &Items{Titles: titles}, nil
 }
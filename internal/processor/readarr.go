@@ -2,137 +2,256 @@ package processor
 
 import (
 	"context"
+	"fmt"
+	"regexp"
 	"strings"
-	"time"
 
 	"github.com/autobrr/omegabrr/internal/domain"
-	"github.com/autobrr/omegabrr/pkg/autobrr"
-	"github.com/pkg/errors"
 
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
-	"golift.io/starr"
 	"golift.io/starr/readarr"
 )
 
-func (s Service) readarr(ctx context.Context, cfg *domain.ArrConfig, dryRun bool, brr *autobrr.Client) error {
-	l := log.With().Str("type", "readarr").Str("client", cfg.Name).Logger()
+func init() {
+	RegisterArrSource(domain.ArrTypeReadarr, newReadarrSource)
+}
 
-	l.Debug().Msgf("gathering titles...")
+// seriesPosition matches the position of a book in its series, like ", #1" or " #2.5".
+var seriesPosition = regexp.MustCompile(`,?\s*#[\d.\-]+\s*$`)
+
+type readarrSource struct {
+	arrSource
+}
 
-	titles, err := s.processReadarr(ctx, cfg, &l)
+// Readarr only supports the Match releases field.
+func newReadarrSource(cfg *domain.ArrConfig) Source {
+	return &readarrSource{arrSource{cfg: cfg, field: FieldMatchReleases}}
+}
+
+func (s *readarrSource) Fetch(ctx context.Context, logger *zerolog.Logger) (*Items, error) {
+	r := readarr.New(newStarrConfig(s.cfg))
+
+	tags, err := s.fetchTags(ctx, r, logger)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	l.Debug().Msgf("got %v filter titles", len(titles))
-
-	joinedTitles := strings.Join(titles, ",")
+	profiles, err := s.fetchProfiles(ctx, r)
+	if err != nil {
+		return nil, err
+	}
 
-	l.Trace().Msgf("%v", joinedTitles)
+	// books have no tags, profiles or author names of their own, they come from their author
+	var authors []*readarr.Author
+	selectsByTag := len(s.cfg.TagsInclude) > 0 || len(s.cfg.TagsExclude) > 0
+	if selectsByTag || profiles != nil || s.cfg.Emits(domain.ArrEmitAuthorTitles) || s.cfg.Emits(domain.ArrEmitAuthors) {
+		if authors, err = fetchAuthors(ctx, r); err != nil {
+			return nil, err
+		}
+	}
 
-	if len(joinedTitles) == 0 {
-		return nil
+	authorsByID := make(map[int64]*readarr.Author, len(authors))
+	for _, author := range authors {
+		authorsByID[author.ID] = author
 	}
 
-	for _, filterID := range cfg.Filters {
+	t := newReadarrTitles()
+
+	if s.cfg.Emits(domain.ArrEmitTitles) || s.cfg.Emits(domain.ArrEmitAuthorTitles) || s.cfg.Emits(domain.ArrEmitSeries) {
+		books, err := r.GetBookContext(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Debug().Msgf("found %d books to process", len(books))
+
+		var processedBooks int
 
-		l.Debug().Msgf("updating filter: %v", filterID)
+		for _, book := range books {
+			author := authorsByID[book.AuthorID]
 
-		if !dryRun {
-			f := autobrr.UpdateFilter{MatchReleases: joinedTitles}
+			var authorTags []int
+			if author != nil {
+				authorTags = author.Tags
+			}
+
+			if !s.selects(tags, book.Monitored, authorTags) || !profiles.includes(author) {
+				continue
+			}
 
-			if !dryRun {
-				if err := brr.UpdateFilterByID(ctx, filterID, f); err != nil {
-					l.Error().Err(err).Msgf("error updating filter: %v", filterID)
-					return errors.Wrapf(err, "error updating filter: %v", filterID)
+			processedBooks++
+
+			if s.cfg.Emits(domain.ArrEmitTitles) {
+				t.add(book.Title)
+			}
+			if s.cfg.Emits(domain.ArrEmitAuthorTitles) && author != nil {
+				// releases are usually named like "Author - Title", this turns into Author*Title
+				t.add(fmt.Sprintf("%s - %s", author.AuthorName, book.Title))
+			}
+			if s.cfg.Emits(domain.ArrEmitSeries) {
+				for _, series := range seriesNames(book.SeriesTitle) {
+					t.add(series)
 				}
 			}
 		}
 
-		l.Debug().Msgf("successfully updated filter: %v", filterID)
+		logger.Debug().Msgf("from a total of %d books we found %d books", len(books), processedBooks)
 	}
 
-	return nil
-}
+	if s.cfg.Emits(domain.ArrEmitAuthors) {
+		var processedAuthors int
 
-func (s Service) processReadarr(ctx context.Context, cfg *domain.ArrConfig, logger *zerolog.Logger) ([]string, error) {
-	c := starr.New(cfg.Apikey, cfg.Host, 60*time.Second)
+		for _, author := range authors {
+			if !s.selects(tags, author.Monitored, author.Tags) || !profiles.includes(author) {
+				continue
+			}
+
+			processedAuthors++
 
-	if cfg.BasicAuth != nil {
-		if cfg.BasicAuth.User != "" {
-			c.HTTPUser = cfg.BasicAuth.User
+			t.add(author.AuthorName)
 		}
-		if cfg.BasicAuth.Pass != "" {
-			c.HTTPPass = cfg.BasicAuth.Pass
+
+		logger.Debug().Msgf("from a total of %d authors we found %d authors", len(authors), processedAuthors)
+	}
+
+	return &Items{Titles: t.titles}, nil
+}
+
+// readarrTitles collects titles once each, since series and authors repeat across books.
+type readarrTitles struct {
+	titles []string
+	seen   map[string]struct{}
+}
+
+func newReadarrTitles() *readarrTitles {
+	return &readarrTitles{seen: make(map[string]struct{})}
+}
+
+func (t *readarrTitles) add(title string) {
+	if title == "" {
+		return
+	}
+	if _, ok := t.seen[title]; ok {
+		return
+	}
+
+	t.seen[title] = struct{}{}
+	t.titles = append(t.titles, title)
+}
+
+// seriesNames returns the names of the series of a book without its position,
+// from a series title like "Mistborn #1; The Cosmere #3".
+func seriesNames(seriesTitle string) []string {
+	var names []string
+	for _, series := range strings.Split(seriesTitle, ";") {
+		if name := strings.TrimSpace(seriesPosition.ReplaceAllString(series, "")); name != "" {
+			names = append(names, name)
 		}
 	}
 
-	r := readarr.New(c)
+	return names
+}
 
-	// I did not find support for tags here.
-	//
-	//	var tags []*starr.Tag
-	//	if len(cfg.TagsExclude) > 0 || len(cfg.TagsInclude) > 0 {
-	//		t, err := r.GetTagsContext(ctx)
-	//		if err != nil {
-	//			logger.Debug().Msg("could not get tags")
-	//		}
-	//		tags = t
-	//	}
+// fetchAuthors returns every author, with a single request.
+func fetchAuthors(ctx context.Context, r *readarr.Readarr) ([]*readarr.Author, error) {
+	var authors []*readarr.Author
+	if err := r.GetInto(ctx, "v1/author", nil, &authors); err != nil {
+		return nil, errors.Wrap(err, "could not get authors")
+	}
 
-	ebooks, err := r.GetBookContext(ctx, "")
-	if err != nil {
-		return nil, err
+	return authors, nil
+}
+
+// readarrProfiles are the IDs of the quality and metadata profiles authors are
+// included by. A nil readarrProfiles includes every author.
+type readarrProfiles struct {
+	quality  map[int64]struct{}
+	metadata map[int64]struct{}
+}
+
+// fetchProfiles looks up the IDs of the configured quality and metadata profiles.
+// Unknown names fail the source, instead of leaving the filter empty.
+func (s *readarrSource) fetchProfiles(ctx context.Context, r *readarr.Readarr) (*readarrProfiles, error) {
+	if len(s.cfg.QualityProfiles) == 0 && len(s.cfg.MetadataProfiles) == 0 {
+		return nil, nil
 	}
 
-	logger.Debug().Msgf("found %d ebooks to process", len(ebooks))
+	profiles := &readarrProfiles{}
 
-	var titles []string
-	var monitoredTitles int
+	if len(s.cfg.QualityProfiles) > 0 {
+		quality, err := r.GetQualityProfilesContext(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get quality profiles")
+		}
 
-	for _, ebook := range ebooks {
-		m := ebook
+		names := make(map[string]int64, len(quality))
+		for _, p := range quality {
+			names[p.Name] = p.ID
+		}
+		if profiles.quality, err = profileIDs("quality", s.cfg.QualityProfiles, names); err != nil {
+			return nil, err
+		}
+	}
 
-		// only want monitored
-		if !m.Monitored {
-			continue
+	if len(s.cfg.MetadataProfiles) > 0 {
+		metadata, err := r.GetMetadataProfilesContext(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get metadata profiles")
 		}
 
-		// I did not find support for tags here
-		//
-		//		if len(cfg.TagsInclude) > 0 {
-		//			if len(m.Tags) == 0 {
-		//				continue
-		//			}
-		//			if !containsTag(tags, m.Tags, cfg.TagsInclude) {
-		//				continue
-		//			}
-		//		}
-		//
-		//		if len(cfg.TagsExclude) > 0 {
-		//			if containsTag(tags, m.Tags, cfg.TagsExclude) {
-		//				continue
-		//			}
-		//		}
+		names := make(map[string]int64, len(metadata))
+		for _, p := range metadata {
+			names[p.Name] = p.ID
+		}
+		if profiles.metadata, err = profileIDs("metadata", s.cfg.MetadataProfiles, names); err != nil {
+			return nil, err
+		}
+	}
 
-		// increment monitored titles
-		monitoredTitles++
+	return profiles, nil
+}
 
-		//titles = append(titles, rls.MustNormalize(m.Title))
-		//titles = append(titles, rls.MustNormalize(m.OriginalTitle))
-		//titles = append(titles, rls.MustClean(m.Title))
+// profileIDs returns the IDs of the wanted profiles, matching names case-insensitively.
+func profileIDs(kind string, wanted []string, names map[string]int64) (map[int64]struct{}, error) {
+	ids := make(map[int64]struct{}, len(wanted))
 
-		titles = append(titles, processTitle(m.Title, cfg.MatchRelease)...)
+	for _, want := range wanted {
+		found := false
+		for name, id := range names {
+			if strings.EqualFold(name, want) {
+				ids[id] = struct{}{}
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("unknown %s profile %q", kind, want)
+		}
+	}
 
-		//	titles = append(titles, processTitle(m.OriginalTitle)...)
+	return ids, nil
+}
 
-		//for _, title := range m.AlternateTitles {
-		//	titles = append(titles, processTitle(title.Title)...)
-		//}
+// includes reports whether the author has one of the profiles. Books of unknown
+// authors are only included when no profiles are configured.
+func (p *readarrProfiles) includes(author *readarr.Author) bool {
+	if p == nil {
+		return true
+	}
+	if author == nil {
+		return false
 	}
 
-	logger.Debug().Msgf("from a total of %d ebooks we found %d monitored and created %d release titles", len(ebooks), monitoredTitles, len(titles))
+	if p.quality != nil {
+		if _, ok := p.quality[int64(author.QualityProfileID)]; !ok {
+			return false
+		}
+	}
+	if p.metadata != nil {
+		if _, ok := p.metadata[int64(author.MetadataProfileID)]; !ok {
+			return false
+		}
+	}
 
-	return titles, nil
+	return true
 }
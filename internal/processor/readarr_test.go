@@ -0,0 +1,111 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadarr_Fetch(t *testing.T) {
+	ts := newArrServer(t, map[string]string{
+		"/api/v1/tag":    `[{"id": 1, "label": "fantasy"}, {"id": 2, "label": "skip"}]`,
+		"/api/v1/author": `[{"id": 10, "authorName": "Brandon Sanderson", "tags": [1]}, {"id": 11, "authorName": "Someone Else", "tags": [1, 2]}, {"id": 12, "authorName": "Untagged"}]`,
+		"/api/v1/book": `[
+			{"title": "Mistborn", "authorId": 10, "monitored": true},
+			{"title": "Elantris", "authorId": 10, "monitored": false},
+			{"title": "Excluded", "authorId": 11, "monitored": true},
+			{"title": "Not Tagged", "authorId": 12, "monitored": true}
+		]`,
+	})
+
+	tests := []struct {
+		name string
+		cfg  domain.ArrConfig
+		want []string
+	}{
+		{name: "monitored", want: []string{"Mistborn", "Excluded", "Not Tagged"}},
+		{name: "unmonitored", cfg: domain.ArrConfig{IncludeUnmonitored: true}, want: []string{"Mistborn", "Elantris", "Excluded", "Not Tagged"}},
+		{name: "tags", cfg: domain.ArrConfig{TagsInclude: []string{"fantasy"}, TagsExclude: []string{"skip"}}, want: []string{"Mistborn"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Name, cfg.Type, cfg.Host, cfg.Apikey = "readarr", domain.ArrTypeReadarr, ts.URL, "key"
+
+			items, err := newArrSource(&cfg).Fetch(context.Background(), &log.Logger)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, items.Titles)
+		})
+	}
+}
+
+func TestReadarr_Fetch_options(t *testing.T) {
+	ts := newArrServer(t, map[string]string{
+		"/api/v1/qualityprofile":  `[{"id": 1, "name": "eBook"}, {"id": 2, "name": "Spoken"}]`,
+		"/api/v1/metadataprofile": `[{"id": 1, "name": "Standard"}, {"id": 2, "name": "None"}]`,
+		"/api/v1/author": `[
+			{"id": 10, "authorName": "Brandon Sanderson", "monitored": true, "qualityProfileId": 1, "metadataProfileId": 1},
+			{"id": 11, "authorName": "Stephen Fry", "monitored": true, "qualityProfileId": 2, "metadataProfileId": 1},
+			{"id": 12, "authorName": "Unmonitored", "monitored": false, "qualityProfileId": 1, "metadataProfileId": 2}
+		]`,
+		"/api/v1/book": `[
+			{"title": "The Final Empire", "seriesTitle": "Mistborn #1; The Cosmere #2", "authorId": 10, "monitored": true},
+			{"title": "The Well of Ascension", "seriesTitle": "Mistborn, #2", "authorId": 10, "monitored": true},
+			{"title": "Mythos", "authorId": 11, "monitored": true}
+		]`,
+	})
+
+	tests := []struct {
+		name string
+		cfg  domain.ArrConfig
+		want []string
+	}{
+		{
+			name: "author titles",
+			cfg:  domain.ArrConfig{Emit: []domain.ArrEmit{domain.ArrEmitAuthorTitles}},
+			want: []string{"Brandon Sanderson - The Final Empire", "Brandon Sanderson - The Well of Ascension", "Stephen Fry - Mythos"},
+		},
+		{
+			name: "authors",
+			cfg:  domain.ArrConfig{Emit: []domain.ArrEmit{domain.ArrEmitAuthors}},
+			want: []string{"Brandon Sanderson", "Stephen Fry"},
+		},
+		{
+			name: "series",
+			cfg:  domain.ArrConfig{Emit: []domain.ArrEmit{domain.ArrEmitSeries}},
+			want: []string{"Mistborn", "The Cosmere"},
+		},
+		{
+			name: "quality profiles",
+			cfg:  domain.ArrConfig{Emit: []domain.ArrEmit{domain.ArrEmitTitles, domain.ArrEmitAuthors}, QualityProfiles: []string{"spoken"}},
+			want: []string{"Mythos", "Stephen Fry"},
+		},
+		{
+			name: "metadata profiles",
+			cfg:  domain.ArrConfig{Emit: []domain.ArrEmit{domain.ArrEmitAuthors}, MetadataProfiles: []string{"None"}, IncludeUnmonitored: true},
+			want: []string{"Unmonitored"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Name, cfg.Type, cfg.Host, cfg.Apikey = "readarr", domain.ArrTypeReadarr, ts.URL, "key"
+
+			items, err := newArrSource(&cfg).Fetch(context.Background(), &log.Logger)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, items.Titles)
+		})
+	}
+
+	t.Run("unknown profile", func(t *testing.T) {
+		cfg := domain.ArrConfig{Name: "readarr", Type: domain.ArrTypeReadarr, Host: ts.URL, Apikey: "key", QualityProfiles: []string{"Audio"}}
+
+		_, err := newArrSource(&cfg).Fetch(context.Background(), &log.Logger)
+		assert.EqualError(t, err, `unknown quality profile "Audio"`)
+	})
+}
@@ -2,11 +2,12 @@ package processor
 
 import (
 	"context"
-	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/autobrr/omegabrr/internal/domain"
+	"github.com/autobrr/omegabrr/internal/history"
 	"github.com/autobrr/omegabrr/pkg/autobrr"
 
 	"github.com/pkg/errors"
@@ -17,6 +18,18 @@ type Service struct {
 	cfg           *domain.Config
 	httpClient    *http.Client
 	autobrrClient *autobrr.Client
+	fingerprints  *fingerprints
+	store         *history.Store
+	runs          *runTracker
+
+	// reloaded is shared by every copy of the Service, so a config swapped in by
+	// SetConfig is picked up by the next run.
+	reloaded *atomic.Pointer[reloadedConfig]
+}
+
+type reloadedConfig struct {
+	cfg           *domain.Config
+	autobrrClient *autobrr.Client
 }
 
 func NewService(cfg *domain.Config) *Service {
@@ -25,6 +38,9 @@ func NewService(cfg *domain.Config) *Service {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		fingerprints: newFingerprints(),
+		runs:         newRunTracker(),
+		reloaded:     &atomic.Pointer[reloadedConfig]{},
 	}
 
 	if cfg != nil {
@@ -33,6 +49,54 @@ func NewService(cfg *domain.Config) *Service {
 	return s
 }
 
+// SetConfig swaps in a reloaded config. Runs in progress finish with the config
+// they started with.
+func (s *Service) SetConfig(cfg *domain.Config) error {
+	a := cfg.Clients.Autobrr
+	if a == nil || a.Host == "" || a.Apikey == "" {
+		return errors.New("must supply autobrr host and apikey")
+	}
+
+	client := autobrr.NewClient(a.Host, a.Apikey)
+	if a.BasicAuth != nil {
+		client.SetBasicAuth(a.BasicAuth.User, a.BasicAuth.Pass)
+	}
+
+	s.reloaded.Store(&reloadedConfig{cfg: cfg, autobrrClient: client})
+
+	return nil
+}
+
+// latest returns a copy of the service with the most recent config.
+func (s Service) latest() Service {
+	if s.reloaded == nil {
+		return s
+	}
+
+	if r := s.reloaded.Load(); r != nil {
+		s.cfg = r.cfg
+		s.autobrrClient = r.autobrrClient
+	}
+
+	return s
+}
+
+// SetStore records runs in the history store and picks up the filter state of
+// earlier runs, so unchanged filters are skipped across restarts too.
+func (s *Service) SetStore(store *history.Store) {
+	s.store = store
+
+	states, err := store.FilterStates()
+	if err != nil {
+		log.Warn().Err(err).Msg("could not load filter state from history")
+		return
+	}
+
+	for _, state := range states {
+		s.fingerprints.set(state.ID, state.Fingerprint)
+	}
+}
+
 func (s Service) newAutobrrClient() *autobrr.Client {
 	if s.cfg.Clients.Autobrr == nil {
 		log.Fatal().Msg("must supply omegabrr configuration!")
@@ -48,106 +112,121 @@ func (s Service) newAutobrrClient() *autobrr.Client {
 }
 
 // shouldProcessItem determines if an item should be processed based on its monitored status and configuration
-func (s Service) shouldProcessItem(monitored bool, arrConfig *domain.ArrConfig) bool {
+func shouldProcessItem(monitored bool, arrConfig *domain.ArrConfig) bool {
 	if arrConfig.IncludeUnmonitored {
 		return true
 	}
 	return monitored
 }
 
-func (s Service) ProcessArrs(ctx context.Context, dryRun bool) []string {
-	var processingErrors []string
-
-	a := s.autobrrClient
-
-	if s.cfg.Clients.Arr != nil {
-		for _, arrClient := range s.cfg.Clients.Arr {
-			arrClient := arrClient
-
-			switch arrClient.Type {
-			case domain.ArrTypeRadarr:
-				if err := s.radarr(ctx, arrClient, dryRun, a); err != nil {
-					log.Error().Err(err).Str("type", "radarr").Str("client", arrClient.Name).Msg("error while processing Radarr, continuing with other clients")
-					processingErrors = append(processingErrors, fmt.Sprintf("Radarr - %s: %v", arrClient.Name, err))
-				}
+func (s Service) ProcessArrs(ctx context.Context, trigger domain.RunTrigger, dryRun bool) *domain.RunReport {
+	return s.Run(ctx, RunOptions{Trigger: trigger, Kind: SourceKindArr, DryRun: dryRun})
+}
 
-			case domain.ArrTypeWhisparr:
-				if err := s.sonarr(ctx, arrClient, dryRun, a); err != nil {
-					log.Error().Err(err).Str("type", "whisparr").Str("client", arrClient.Name).Msg("error while processing Whisparr, continuing with other clients")
-					processingErrors = append(processingErrors, fmt.Sprintf("Whisparr - %s: %v", arrClient.Name, err))
-				}
+func (s Service) ProcessLists(ctx context.Context, trigger domain.RunTrigger, dryRun bool) *domain.RunReport {
+	return s.Run(ctx, RunOptions{Trigger: trigger, Kind: SourceKindList, DryRun: dryRun})
+}
 
-			case domain.ArrTypeSonarr:
-				if err := s.sonarr(ctx, arrClient, dryRun, a); err != nil {
-					log.Error().Err(err).Str("type", "sonarr").Str("client", arrClient.Name).Msg("error while processing Sonarr, continuing with other clients")
-					processingErrors = append(processingErrors, fmt.Sprintf("Sonarr - %s: %v", arrClient.Name, err))
-				}
+// sources builds a Source for every configured arr client, list and set. Arrs and
+// lists are cached so sets and their own filters share a single fetch per run.
+// Disabled ones are included, so they are reported as skipped.
+func (s Service) sources() []Source {
+	var sources []Source
+	byName := make(map[string]Source)
+
+	for _, arrClient := range s.cfg.Clients.Arr {
+		src := disableUnless(arrClient.IsEnabled(), newCachedSource(newArrSource(arrClient)))
+		sources = append(sources, src)
+		byName[arrClient.Name] = src
+	}
 
-			case domain.ArrTypeReadarr:
-				if err := s.readarr(ctx, arrClient, dryRun, a); err != nil {
-					log.Error().Err(err).Str("type", "readarr").Str("client", arrClient.Name).Msg("error while processing Readarr, continuing with other clients")
-					processingErrors = append(processingErrors, fmt.Sprintf("Readarr - %s: %v", arrClient.Name, err))
-				}
+	for _, listsClient := range s.cfg.Lists {
+		src := disableUnless(listsClient.IsEnabled(), newCachedSource(newListSource(listsClient, s.httpClient)))
+		sources = append(sources, src)
+		byName[listsClient.Name] = src
+	}
 
-			case domain.ArrTypeLidarr:
-				if err := s.lidarr(ctx, arrClient, dryRun, a); err != nil {
-					log.Error().Err(err).Str("type", "lidarr").Str("client", arrClient.Name).Msg("error while processing Lidarr, continuing with other clients")
-					processingErrors = append(processingErrors, fmt.Sprintf("Lidarr - %s: %v", arrClient.Name, err))
-				}
-			}
-		}
+	for _, set := range s.cfg.Sets {
+		sources = append(sources, disableUnless(set.IsEnabled(), newSetSource(set, byName)))
 	}
 
-	return processingErrors
+	return sources
 }
 
-func (s Service) ProcessLists(ctx context.Context, dryRun bool) []string {
-	var processingErrors []string
+// selectSources returns the matching sources together with everything needed to
+// rebuild their filters in full: sets using them, the members of those sets, and
+// any other source writing to the same filters.
+func selectSources(all []Source, match func(SourceInfo) bool) []Source {
+	selected := make(map[Source]struct{})
+	for _, src := range all {
+		if match(src.Info()) {
+			selected[src] = struct{}{}
+		}
+	}
 
-	a := s.autobrrClient
+	for changed := true; changed; {
+		changed = false
 
-	if s.cfg.Lists != nil {
-		for _, listsClient := range s.cfg.Lists {
-			listsClient := listsClient
+		filters := make(map[int]struct{})
+		for src := range selected {
+			for _, id := range src.Info().Filters {
+				filters[id] = struct{}{}
+			}
+		}
 
-			switch listsClient.Type {
-			case domain.ListTypeTrakt:
-				if err := s.trakt(ctx, listsClient, dryRun, a); err != nil {
-					log.Error().Err(err).Str("type", "trakt").Str("client", listsClient.Name).Msg("error while processing Trakt list, continuing with other lists")
-					processingErrors = append(processingErrors, fmt.Sprintf("Trakt - %s: %v", listsClient.Name, err))
+		for _, src := range all {
+			if _, ok := selected[src]; ok {
+				if set, ok := src.(*setSource); ok {
+					for _, member := range set.members {
+						if _, ok := selected[member]; !ok {
+							selected[member] = struct{}{}
+							changed = true
+						}
+					}
 				}
+				continue
+			}
 
-			case domain.ListTypeMdblist:
-				if err := s.mdblist(ctx, listsClient, dryRun, a); err != nil {
-					log.Error().Err(err).Str("type", "mdblist").Str("client", listsClient.Name).Msg("error while processing Mdblist, continuing with other lists")
-					processingErrors = append(processingErrors, fmt.Sprintf("Mdblist - %s: %v", listsClient.Name, err))
-				}
+			if needsSource(src, selected, filters) {
+				selected[src] = struct{}{}
+				changed = true
+			}
+		}
+	}
 
-			case domain.ListTypeMetacritic:
-				if err := s.metacritic(ctx, listsClient, dryRun, a); err != nil {
-					log.Error().Err(err).Str("type", "metacritic").Str("client", listsClient.Name).Msg("error while processing Metacritic, continuing with other lists")
-					processingErrors = append(processingErrors, fmt.Sprintf("Metacritic - %s: %v", listsClient.Name, err))
-				}
+	// keep the configured order
+	var ordered []Source
+	for _, src := range all {
+		if _, ok := selected[src]; ok {
+			ordered = append(ordered, src)
+		}
+	}
 
-			case domain.ListTypePlaintext:
-				if err := s.plaintext(ctx, listsClient, dryRun, a); err != nil {
-					log.Error().Err(err).Str("type", "plaintext").Str("client", listsClient.Name).Msg("error while processing Plaintext list, continuing with other lists")
-					processingErrors = append(processingErrors, fmt.Sprintf("Plaintext - %s: %v", listsClient.Name, err))
-				}
+	return ordered
+}
 
-			case domain.ListTypeSteam:
-				if err := s.steam(ctx, listsClient, dryRun, a); err != nil {
-					log.Error().Err(err).Str("type", "steam").Str("client", listsClient.Name).Msg("error while processing Steam wishlist, continuing with other lists")
-					processingErrors = append(processingErrors, fmt.Sprintf("Steam - %s: %v", listsClient.Name, err))
-				}
+// needsSource reports whether src writes to one of the filters or is a set using a selected source.
+func needsSource(src Source, selected map[Source]struct{}, filters map[int]struct{}) bool {
+	for _, id := range src.Info().Filters {
+		if _, ok := filters[id]; ok {
+			return true
+		}
+	}
+
+	if set, ok := src.(*setSource); ok {
+		for _, member := range set.members {
+			if _, ok := selected[member]; ok {
+				return true
 			}
 		}
 	}
 
-	return processingErrors
+	return false
 }
 
 func (s Service) GetFilters(ctx context.Context) ([]autobrr.Filter, error) {
+	s = s.latest()
+
 	if s.autobrrClient == nil {
 		log.Fatal().Msg("must supply omegabrr configuration!")
 		return nil, errors.New("must supply omegabrr configuration")
@@ -9,9 +9,26 @@ import (
 )
 
 func TestService_shouldProcessItem(t *testing.T) {
-	s := &Service{}
 	cfg := &domain.ArrConfig{
 		IncludeUnmonitored: true,
 	}
-	assert.True(t, s.shouldProcessItem(false, cfg), "unmonitored items should be processed when includeUnmonitored is true")
+	assert.True(t, shouldProcessItem(false, cfg), "unmonitored items should be processed when includeUnmonitored is true")
+}
+
+func TestService_SetConfig(t *testing.T) {
+	cfg := &domain.Config{}
+	cfg.Clients.Autobrr = &domain.AutobrrConfig{Host: "http://localhost:7474", Apikey: "key"}
+
+	s := NewService(cfg)
+
+	reloaded := &domain.Config{}
+	assert.Error(t, s.SetConfig(reloaded), "a config without autobrr should be rejected")
+	assert.Same(t, cfg, s.latest().cfg)
+
+	reloaded.Clients.Autobrr = &domain.AutobrrConfig{Host: "http://autobrr:7474", Apikey: "key"}
+	assert.NoError(t, s.SetConfig(reloaded))
+
+	latest := s.latest()
+	assert.Same(t, reloaded, latest.cfg)
+	assert.Equal(t, "http://autobrr:7474", latest.autobrrClient.Host)
 }
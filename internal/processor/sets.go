@@ -0,0 +1,208 @@
+package processor
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+var SourceKindSet SourceKind = "set"
+
+// cachedSource fetches the wrapped source at most once, so a source used both on
+// its own and by one or more sets is only requested once per run.
+type cachedSource struct {
+	Source
+
+	once  sync.Once
+	items *Items
+	err   error
+}
+
+func newCachedSource(src Source) *cachedSource {
+	return &cachedSource{Source: src}
+}
+
+func (s *cachedSource) Fetch(ctx context.Context, logger *zerolog.Logger) (*Items, error) {
+	s.once.Do(func() {
+		s.items, s.err = s.Source.Fetch(ctx, logger)
+	})
+
+	return s.items, s.err
+}
+
+// setSource combines the items of other sources with a set operation. Disabled
+// sources are left out of it.
+type setSource struct {
+	cfg      *domain.SetConfig
+	members  []Source
+	missing  []string
+	disabled []string
+}
+
+func newSetSource(cfg *domain.SetConfig, byName map[string]Source) *setSource {
+	s := &setSource{cfg: cfg}
+
+	for _, name := range cfg.Sources {
+		src, ok := byName[name]
+		if !ok {
+			s.missing = append(s.missing, name)
+			continue
+		}
+		if src.Info().Disabled {
+			s.disabled = append(s.disabled, name)
+			continue
+		}
+		s.members = append(s.members, src)
+	}
+
+	return s
+}
+
+func (s *setSource) Info() SourceInfo {
+	info := SourceInfo{
+		Name:         s.cfg.Name,
+		Type:         string(s.cfg.Operation),
+		Kind:         SourceKindSet,
+		Filters:      s.cfg.Filters,
+		MatchRelease: s.cfg.MatchRelease,
+		// nothing is left to combine once all sources are disabled
+		Disabled: len(s.members) == 0 && len(s.disabled) > 0 && len(s.missing) == 0,
+	}
+
+	// titles end up in the same field as those of the first source
+	if len(s.members) > 0 {
+		info.Field = s.members[0].Info().Field
+	}
+
+	return info
+}
+
+func (s *setSource) Validate() error {
+	switch s.cfg.Operation {
+	case domain.SetOperationUnion, domain.SetOperationIntersection, domain.SetOperationDifference:
+	default:
+		return errors.Errorf("unsupported set operation: %q", s.cfg.Operation)
+	}
+
+	if len(s.missing) > 0 {
+		return errors.Errorf("unknown sources in set %s: %s", s.cfg.Name, strings.Join(s.missing, ", "))
+	}
+
+	if len(s.members) == 0 {
+		return errors.Errorf("no sources provided for set: %s", s.cfg.Name)
+	}
+
+	for _, member := range s.members {
+		if err := member.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid source %s", member.Info().Name)
+		}
+	}
+
+	return nil
+}
+
+func (s *setSource) Fetch(ctx context.Context, logger *zerolog.Logger) (*Items, error) {
+	var titles, artists [][]string
+
+	for _, member := range s.members {
+		info := member.Info()
+
+		l := logger.With().Str("source", info.Name).Logger()
+
+		items, err := member.Fetch(ctx, &l)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not fetch source %s", info.Name)
+		}
+
+		titles = append(titles, items.Titles)
+		artists = append(artists, items.Artists)
+	}
+
+	combined := &Items{
+		Titles:  combineTitles(s.cfg.Operation, titles),
+		Artists: combineTitles(s.cfg.Operation, artists),
+	}
+
+	logger.Debug().Msgf("%s of %d sources gave %d titles", s.cfg.Operation, len(s.members), len(combined.Titles))
+
+	return combined, nil
+}
+
+// combineTitles applies the set operation to the title lists from left to right.
+// Titles are compared case-insensitively and without punctuation, and the first
+// spelling seen is kept.
+func combineTitles(op domain.SetOperation, sets [][]string) []string {
+	if len(sets) == 0 {
+		return nil
+	}
+
+	result, order := titleSet(sets[0])
+
+	for _, titles := range sets[1:] {
+		other, otherOrder := titleSet(titles)
+
+		switch op {
+		case domain.SetOperationUnion:
+			for _, key := range otherOrder {
+				if _, ok := result[key]; !ok {
+					result[key] = other[key]
+					order = append(order, key)
+				}
+			}
+		case domain.SetOperationIntersection:
+			for key := range result {
+				if _, ok := other[key]; !ok {
+					delete(result, key)
+				}
+			}
+		case domain.SetOperationDifference:
+			for key := range other {
+				delete(result, key)
+			}
+		}
+	}
+
+	combined := make([]string, 0, len(result))
+	for _, key := range order {
+		if title, ok := result[key]; ok {
+			combined = append(combined, title)
+		}
+	}
+
+	return combined
+}
+
+func titleSet(titles []string) (map[string]string, []string) {
+	set := make(map[string]string, len(titles))
+	order := make([]string, 0, len(titles))
+
+	for _, title := range titles {
+		key := titleKey(title)
+		if key == "" {
+			continue
+		}
+		if _, ok := set[key]; ok {
+			continue
+		}
+		set[key] = title
+		order = append(order, key)
+	}
+
+	return set, order
+}
+
+// titleKey normalises a title for comparison between sources.
+func titleKey(title string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, title)
+}
@@ -0,0 +1,76 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_combineTitles(t *testing.T) {
+	trakt := []string{"Dune: Part Two", "Alien", "The Matrix"}
+	radarr := []string{"the matrix", "Dune Part Two", "Heat"}
+
+	tests := []struct {
+		name string
+		op   domain.SetOperation
+		want []string
+	}{
+		{
+			name: "union",
+			op:   domain.SetOperationUnion,
+			want: []string{"Dune: Part Two", "Alien", "The Matrix", "Heat"},
+		},
+		{
+			name: "intersection",
+			op:   domain.SetOperationIntersection,
+			want: []string{"Dune: Part Two", "The Matrix"},
+		},
+		{
+			name: "difference",
+			op:   domain.SetOperationDifference,
+			want: []string{"Alien"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, combineTitles(tt.op, [][]string{trakt, radarr}))
+		})
+	}
+}
+
+func Test_selectSources_sets(t *testing.T) {
+	radarr := &unsupportedSource{info: SourceInfo{Name: "radarr", Kind: SourceKindArr}}
+	trakt := &unsupportedSource{info: SourceInfo{Name: "trakt", Kind: SourceKindList}}
+	other := &unsupportedSource{info: SourceInfo{Name: "other", Kind: SourceKindList, Filters: []int{3}}}
+
+	set := newSetSource(&domain.SetConfig{
+		Name:      "trakt-not-in-radarr",
+		Operation: domain.SetOperationDifference,
+		Sources:   []string{"trakt", "radarr"},
+		Filters:   []int{3},
+	}, map[string]Source{"radarr": radarr, "trakt": trakt})
+
+	all := []Source{radarr, trakt, other, set}
+
+	var names []string
+	for _, src := range selectSources(all, RunOptions{Kind: SourceKindArr}.matches) {
+		names = append(names, src.Info().Name)
+	}
+
+	assert.Equal(t, []string{"radarr", "trakt", "other", "trakt-not-in-radarr"}, names)
+}
+
+func Test_newSetSource_disabledMembers(t *testing.T) {
+	radarr := &fakeSource{info: SourceInfo{Name: "radarr"}}
+	trakt := disableUnless(false, &fakeSource{info: SourceInfo{Name: "trakt"}})
+	byName := map[string]Source{"radarr": radarr, "trakt": trakt}
+
+	set := newSetSource(&domain.SetConfig{Name: "set", Operation: domain.SetOperationUnion, Sources: []string{"radarr", "trakt"}}, byName)
+	assert.Equal(t, []Source{radarr}, set.members)
+	assert.False(t, set.Info().Disabled)
+
+	set = newSetSource(&domain.SetConfig{Name: "set", Operation: domain.SetOperationUnion, Sources: []string{"trakt"}}, byName)
+	assert.True(t, set.Info().Disabled)
+}
@@ -0,0 +1,257 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// SourceKind separates arr clients from external lists.
+type SourceKind string
+
+var (
+	SourceKindArr  SourceKind = "arr"
+	SourceKindList SourceKind = "list"
+)
+
+// Field is the autobrr filter field a source writes its titles to.
+type Field string
+
+var (
+	FieldShows         Field = "shows"
+	FieldAlbums        Field = "albums"
+	FieldArtists       Field = "artists"
+	FieldMatchReleases Field = "match_releases"
+)
+
+// SourceInfo describes a configured source and where its titles end up.
+type SourceInfo struct {
+	Name         string
+	Type         string
+	Kind         SourceKind
+	Filters      []int
+	MatchRelease bool
+
+	// Field receives the titles when MatchRelease is not set.
+	Field Field
+
+	// Disabled sources are reported as skipped instead of being fetched.
+	Disabled bool
+}
+
+// Items are the raw, unprocessed titles fetched from a source.
+type Items struct {
+	Titles  []string
+	Artists []string
+}
+
+// Source is implemented by every arr and list type omegabrr can turn into filters.
+type Source interface {
+	Info() SourceInfo
+	Validate() error
+	Fetch(ctx context.Context, logger *zerolog.Logger) (*Items, error)
+}
+
+type ArrSourceFactory func(cfg *domain.ArrConfig) Source
+
+type ListSourceFactory func(cfg *domain.ListConfig, client *http.Client) Source
+
+var (
+	registryMu  sync.RWMutex
+	arrSources  = map[domain.ArrType]ArrSourceFactory{}
+	listSources = map[domain.ListType]ListSourceFactory{}
+)
+
+// RegisterArrSource makes an arr type available to the processor. Registering an existing type replaces it.
+func RegisterArrSource(arrType domain.ArrType, factory ArrSourceFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	arrSources[arrType] = factory
+}
+
+// RegisterListSource makes a list type available to the processor. Registering an existing type replaces it.
+func RegisterListSource(listType domain.ListType, factory ListSourceFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	listSources[listType] = factory
+}
+
+// newArrSource looks up the registered factory for the arr type. Unsupported types
+// still produce a Source so they are reported like any other failing source.
+func newArrSource(cfg *domain.ArrConfig) Source {
+	registryMu.RLock()
+	factory, ok := arrSources[cfg.Type]
+	registryMu.RUnlock()
+
+	if !ok {
+		return &unsupportedSource{
+			info: SourceInfo{Name: cfg.Name, Type: string(cfg.Type), Kind: SourceKindArr, Filters: cfg.Filters},
+			err:  errors.Errorf("unsupported arr type: %q", cfg.Type),
+		}
+	}
+
+	return factory(cfg)
+}
+
+// newListSource looks up the registered factory for the list type. Unsupported types
+// still produce a Source so they are reported like any other failing source.
+func newListSource(cfg *domain.ListConfig, client *http.Client) Source {
+	registryMu.RLock()
+	factory, ok := listSources[cfg.Type]
+	registryMu.RUnlock()
+
+	if !ok {
+		return &unsupportedSource{
+			info: SourceInfo{Name: cfg.Name, Type: string(cfg.Type), Kind: SourceKindList, Filters: cfg.Filters},
+			err:  errors.Errorf("unsupported list type: %q", cfg.Type),
+		}
+	}
+
+	return factory(cfg, client)
+}
+
+// disabledSource is an arr, list or set with enabled: false.
+type disabledSource struct {
+	Source
+}
+
+func (s *disabledSource) Info() SourceInfo {
+	info := s.Source.Info()
+	info.Disabled = true
+	return info
+}
+
+// disableUnless wraps the source as disabled unless it is enabled.
+func disableUnless(enabled bool, src Source) Source {
+	if enabled {
+		return src
+	}
+	return &disabledSource{Source: src}
+}
+
+type unsupportedSource struct {
+	info SourceInfo
+	err  error
+}
+
+func (s *unsupportedSource) Info() SourceInfo { return s.info }
+
+func (s *unsupportedSource) Validate() error { return s.err }
+
+func (s *unsupportedSource) Fetch(context.Context, *zerolog.Logger) (*Items, error) {
+	return nil, s.err
+}
+
+// arrSource holds what every arr source has in common.
+type arrSource struct {
+	cfg   *domain.ArrConfig
+	field Field
+}
+
+func (s arrSource) Info() SourceInfo {
+	return SourceInfo{
+		Name:         s.cfg.Name,
+		Type:         string(s.cfg.Type),
+		Kind:         SourceKindArr,
+		Filters:      s.cfg.Filters,
+		MatchRelease: s.cfg.MatchRelease,
+		Field:        s.field,
+	}
+}
+
+func (s arrSource) Validate() error {
+	if s.cfg.Host == "" {
+		return errors.Errorf("no host provided for %s: %s", s.cfg.Type, s.cfg.Name)
+	}
+	if s.cfg.Apikey == "" {
+		return errors.Errorf("no apikey provided for %s: %s", s.cfg.Type, s.cfg.Name)
+	}
+
+	return nil
+}
+
+// listSource holds what every list source has in common.
+type listSource struct {
+	cfg    *domain.ListConfig
+	client *http.Client
+	field  Field
+}
+
+func (s listSource) Info() SourceInfo {
+	return SourceInfo{
+		Name:         s.cfg.Name,
+		Type:         string(s.cfg.Type),
+		Kind:         SourceKindList,
+		Filters:      s.cfg.Filters,
+		MatchRelease: s.cfg.MatchRelease,
+		Field:        s.field,
+	}
+}
+
+func (s listSource) Validate() error {
+	if s.cfg.URL == "" {
+		return errors.Errorf("no URL provided for %s list: %s", s.cfg.Type, s.cfg.Name)
+	}
+
+	return nil
+}
+
+// get requests the list URL with the configured basic auth and headers and returns
+// the response if it came back with 200 OK. The caller must close the body.
+func (s listSource) get(ctx context.Context, logger *zerolog.Logger, headers map[string]string) (*http.Response, error) {
+	green := color.New(color.FgGreen).SprintFunc()
+	logger.Debug().Msgf("fetching titles from %s", green(s.cfg.URL))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.cfg.URL, nil)
+	if err != nil {
+		logger.Error().Err(err).Msg("could not make new request")
+		return nil, err
+	}
+
+	// an Authorization header in the config takes precedence
+	if auth := s.cfg.BasicAuth; auth != nil && (auth.User != "" || auth.Pass != "") {
+		req.SetBasicAuth(auth.User, auth.Pass)
+	}
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	setUserAgent(req)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		logger.Error().Err(err).Msgf("failed to fetch titles from URL: %s", s.cfg.URL)
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			errMsg := fmt.Sprintf("No endpoint found at %v. (404 Not Found)", s.cfg.URL)
+			logger.Error().Msg(errMsg)
+			return nil, errors.New(errMsg)
+		}
+
+		logger.Error().Msgf("failed to fetch titles from URL: %s", s.cfg.URL)
+		return nil, fmt.Errorf("failed to fetch titles from URL: %s", s.cfg.URL)
+	}
+
+	return resp, nil
+}
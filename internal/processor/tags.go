@@ -1,9 +1,56 @@
 package processor
 
 import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
 	"golift.io/starr"
 )
 
+// tagsGetter is implemented by the starr client of every arr type.
+type tagsGetter interface {
+	GetTagsContext(ctx context.Context) ([]*starr.Tag, error)
+}
+
+// fetchTags returns the tags of the arr, but only fetches them when items are
+// selected by tag. Going on without them would empty the filter with tagsInclude
+// and add excluded items with tagsExclude, so failing to fetch them fails the source.
+func (s arrSource) fetchTags(ctx context.Context, client tagsGetter, logger *zerolog.Logger) ([]*starr.Tag, error) {
+	if len(s.cfg.TagsInclude) == 0 && len(s.cfg.TagsExclude) == 0 {
+		return nil, nil
+	}
+
+	tags, err := client.GetTagsContext(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get tags")
+	}
+
+	logger.Debug().Msgf("found %d tags", len(tags))
+
+	return tags, nil
+}
+
+// selects reports whether an item is processed, based on whether it is monitored,
+// includeUnmonitored, and its tags matching tagsInclude and tagsExclude. Items of
+// arr types without tags of their own, like albums and books, pass the tags of their
+// artist or author.
+func (s arrSource) selects(tags []*starr.Tag, monitored bool, itemTags []int) bool {
+	if !shouldProcessItem(monitored, s.cfg) {
+		return false
+	}
+
+	if len(s.cfg.TagsInclude) > 0 && !containsTag(tags, itemTags, s.cfg.TagsInclude) {
+		return false
+	}
+
+	if len(s.cfg.TagsExclude) > 0 && containsTag(tags, itemTags, s.cfg.TagsExclude) {
+		return false
+	}
+
+	return true
+}
+
 func containsTag(tags []*starr.Tag, titleTags []int, checkTags []string) bool {
 	tagLabels := []string{}
 
@@ -1,8 +1,17 @@
 package processor
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/autobrr/omegabrr/internal/domain"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"golift.io/starr"
 )
 
@@ -65,3 +74,70 @@ func Test_containsTag(t *testing.T) {
 		})
 	}
 }
+
+func Test_arrSource_selects(t *testing.T) {
+	tags := []*starr.Tag{{ID: 1, Label: "want"}, {ID: 2, Label: "skip"}}
+
+	tests := []struct {
+		name      string
+		cfg       domain.ArrConfig
+		monitored bool
+		itemTags  []int
+		want      bool
+	}{
+		{name: "monitored", monitored: true, want: true},
+		{name: "unmonitored", monitored: false, want: false},
+		{name: "unmonitored included", cfg: domain.ArrConfig{IncludeUnmonitored: true}, want: true},
+		{name: "included tag", cfg: domain.ArrConfig{TagsInclude: []string{"want"}}, monitored: true, itemTags: []int{1}, want: true},
+		{name: "without included tag", cfg: domain.ArrConfig{TagsInclude: []string{"want"}}, monitored: true, itemTags: []int{2}, want: false},
+		{name: "without tags", cfg: domain.ArrConfig{TagsInclude: []string{"want"}}, monitored: true, want: false},
+		{name: "excluded tag", cfg: domain.ArrConfig{TagsExclude: []string{"skip"}}, monitored: true, itemTags: []int{1, 2}, want: false},
+		{name: "unmonitored with included tag", cfg: domain.ArrConfig{TagsInclude: []string{"want"}}, monitored: false, itemTags: []int{1}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := arrSource{cfg: &tt.cfg}
+			assert.Equal(t, tt.want, s.selects(tags, tt.monitored, tt.itemTags))
+		})
+	}
+}
+
+// Failing to fetch tags fails the source when it selects by tag, and tags are not
+// fetched at all when it doesn't.
+func TestRadarr_Fetch_tags(t *testing.T) {
+	tagsFail := true
+	tagRequests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/tag":
+			tagRequests++
+			if tagsFail {
+				http.Error(w, "boom", http.StatusInternalServerError)
+				return
+			}
+			fmt.Fprint(w, `[{"id": 1, "label": "want"}]`)
+		case "/api/v3/movie":
+			fmt.Fprint(w, `[{"title": "Movie", "monitored": true, "tags": [1]}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	newCfg := func(tagsInclude ...string) *domain.ArrConfig {
+		return &domain.ArrConfig{Name: "radarr", Type: domain.ArrTypeRadarr, Host: ts.URL, Apikey: "key", TagsInclude: tagsInclude}
+	}
+
+	_, err := newArrSource(newCfg("want")).Fetch(context.Background(), &log.Logger)
+	assert.ErrorContains(t, err, "could not get tags")
+
+	items, err := newArrSource(newCfg()).Fetch(context.Background(), &log.Logger)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Movie"}, items.Titles)
+	assert.Equal(t, 1, tagRequests)
+
+	tagsFail = false
+	items, err = newArrSource(newCfg("want")).Fetch(context.Background(), &log.Logger)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Movie"}, items.Titles)
+}
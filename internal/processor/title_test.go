@@ -233,3 +233,8 @@ func Test_processTitle(t *testing.T) {
 		})
 	}
 }
+
+// Readarr author titles, like "Author - Title", match releases named that way.
+func Test_processTitles_authorTitle(t *testing.T) {
+	assert.Equal(t, []string{"*Brandon?Sanderson*The?Final?Empire*"}, processTitles([]string{"Brandon Sanderson - The Final Empire"}, true))
+}
@@ -0,0 +1,178 @@
+package processor
+
+import (
+	"context"
+	"sync"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+)
+
+// recentRuns is how many finished runs are kept in memory, so async runs can be
+// polled even when the history store is not available.
+const recentRuns = 20
+
+// subscriberBuffer is how many events a slow subscriber can fall behind before
+// events are dropped for it. A run never waits for its subscribers.
+const subscriberBuffer = 64
+
+// trackedRun is a run that is queued or in progress. The report is a live copy
+// built from the events of the run, the pipeline writes to its own.
+type trackedRun struct {
+	report      *domain.RunReport
+	cancel      context.CancelFunc
+	cancelled   bool
+	subscribers map[chan Event]struct{}
+}
+
+// runTracker keeps the runs that are in progress and the last few that finished.
+type runTracker struct {
+	mu      sync.RWMutex
+	running map[string]*trackedRun
+	recent  []*domain.RunReport
+}
+
+func newRunTracker() *runTracker {
+	return &runTracker{
+		running: make(map[string]*trackedRun),
+	}
+}
+
+func (t *runTracker) queue(run *domain.RunReport) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	snapshot := *run
+	t.running[run.ID] = &trackedRun{
+		report:      &snapshot,
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// start tracks a run in progress. A run that was queued keeps its subscribers,
+// and is cancelled right away if that was asked for while it was queued.
+func (t *runTracker) start(run *domain.RunReport, cancel context.CancelFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tr, ok := t.running[run.ID]
+	if !ok {
+		tr = &trackedRun{subscribers: make(map[chan Event]struct{})}
+		t.running[run.ID] = tr
+	}
+
+	snapshot := *run
+	tr.report = &snapshot
+	tr.cancel = cancel
+
+	if tr.cancelled {
+		cancel()
+	}
+}
+
+// publish updates the live report of the run and passes the event on to its subscribers.
+func (t *runTracker) publish(ev Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tr, ok := t.running[ev.RunID]
+	if !ok {
+		return
+	}
+
+	switch {
+	case ev.Type == EventSourceFinished && ev.Source != nil:
+		tr.report.Sources = append(tr.report.Sources, *ev.Source)
+	case ev.Type == EventFilterUpdated && ev.Filter != nil:
+		tr.report.Filters = append(tr.report.Filters, *ev.Filter)
+	}
+
+	for ch := range tr.subscribers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+func (t *runTracker) finish(run *domain.RunReport) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if tr, ok := t.running[run.ID]; ok {
+		for ch := range tr.subscribers {
+			delete(tr.subscribers, ch)
+			close(ch)
+		}
+		delete(t.running, run.ID)
+	}
+
+	t.recent = append(t.recent, run)
+	if len(t.recent) > recentRuns {
+		t.recent = t.recent[len(t.recent)-recentRuns:]
+	}
+}
+
+func (t *runTracker) get(id string) (*domain.RunReport, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if tr, ok := t.running[id]; ok {
+		// copy, so the caller doesn't race with publish
+		snapshot := *tr.report
+		snapshot.Sources = append([]domain.SourceReport(nil), tr.report.Sources...)
+		snapshot.Filters = append([]domain.FilterReport(nil), tr.report.Filters...)
+		return &snapshot, true
+	}
+
+	for _, run := range t.recent {
+		if run.ID == id {
+			return run, true
+		}
+	}
+
+	return nil, false
+}
+
+// subscribe returns a channel with the events of a run in progress. The channel
+// is closed when the run finishes.
+func (t *runTracker) subscribe(id string) (<-chan Event, func(), bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tr, ok := t.running[id]
+	if !ok {
+		return nil, nil, false
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	tr.subscribers[ch] = struct{}{}
+
+	unsubscribe := func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		if _, ok := tr.subscribers[ch]; ok {
+			delete(tr.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return ch, unsubscribe, true
+}
+
+func (t *runTracker) cancel(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tr, ok := t.running[id]
+	if !ok {
+		return false
+	}
+
+	tr.cancelled = true
+	if tr.cancel != nil {
+		tr.cancel()
+	}
+
+	return true
+}
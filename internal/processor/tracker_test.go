@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_Queue(t *testing.T) {
+	s := Service{cfg: &domain.Config{}, runs: newRunTracker()}
+
+	id := s.Queue(RunOptions{Trigger: domain.RunTriggerWebhook})
+
+	run, ok := s.GetRun(id)
+	assert.True(t, ok)
+	assert.Equal(t, domain.RunStatusQueued, run.Status)
+
+	run = s.Run(context.Background(), RunOptions{ID: id, Trigger: domain.RunTriggerWebhook})
+	assert.Equal(t, id, run.ID)
+	assert.Equal(t, domain.RunStatusSuccess, run.Status)
+
+	_, ok = s.GetRun("unknown")
+	assert.False(t, ok)
+}
+
+func TestService_Cancel_queued(t *testing.T) {
+	s := Service{cfg: &domain.Config{}, runs: newRunTracker()}
+
+	id := s.Queue(RunOptions{Trigger: domain.RunTriggerWebhook})
+	assert.True(t, s.Cancel(id))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.runs.start(&domain.RunReport{ID: id}, cancel)
+	assert.Error(t, ctx.Err())
+}
+
+func Test_runTracker_keepsRecentRuns(t *testing.T) {
+	tr := newRunTracker()
+
+	for i := 0; i < recentRuns+5; i++ {
+		run := &domain.RunReport{ID: newRunID(time.Now())}
+		tr.start(run, func() {})
+		tr.finish(run)
+	}
+
+	assert.Empty(t, tr.running)
+	assert.Len(t, tr.recent, recentRuns)
+}
+
+func Test_runTracker_events(t *testing.T) {
+	tr := newRunTracker()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &domain.RunReport{ID: "run-1"}
+	tr.start(run, cancel)
+
+	events, unsubscribe, ok := tr.subscribe(run.ID)
+	assert.True(t, ok)
+	defer unsubscribe()
+
+	tr.publish(Event{Type: EventSourceFinished, RunID: run.ID, Source: &domain.SourceReport{Name: "radarr"}})
+
+	ev := <-events
+	assert.Equal(t, EventSourceFinished, ev.Type)
+
+	live, ok := tr.get(run.ID)
+	assert.True(t, ok)
+	assert.Len(t, live.Sources, 1)
+
+	assert.True(t, tr.cancel(run.ID))
+	assert.Error(t, ctx.Err())
+
+	tr.finish(run)
+
+	_, open := <-events
+	assert.False(t, open)
+	assert.False(t, tr.cancel(run.ID))
+
+	_, _, ok = tr.subscribe(run.ID)
+	assert.False(t, ok)
+}
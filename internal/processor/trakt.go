@@ -8,57 +8,32 @@ import (
 	"strings"
 
 	"github.com/autobrr/omegabrr/internal/domain"
-	"github.com/autobrr/omegabrr/pkg/autobrr"
-	"github.com/pkg/errors"
 
-	"github.com/fatih/color"
-	"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog"
 )
 
-func (s Service) trakt(ctx context.Context, cfg *domain.ListConfig, dryRun bool, brr *autobrr.Client) error {
-	l := log.With().Str("type", "trakt").Str("client", cfg.Name).Logger()
-
-	if cfg.URL == "" {
-		errMsg := "no URL provided for Trakt list"
-		l.Error().Msg(errMsg)
-		return fmt.Errorf(errMsg)
-	}
-
-	var titles []string
-
-	green := color.New(color.FgGreen).SprintFunc()
-	l.Debug().Msgf("fetching titles from %s", green(cfg.URL))
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.URL, nil)
-	if err != nil {
-		l.Error().Err(err).Msg("could not make new request")
-		return err
-	}
+func init() {
+	RegisterListSource(domain.ListTypeTrakt, newTraktSource)
+}
 
-	req.Header.Set("trakt-api-version", "2")
-	//req.Header.Set("trakt-api-key", t.apiKey)
-	for k, v := range cfg.Headers {
-		req.Header.Set(k, v)
-	}
+type traktSource struct {
+	listSource
+}
 
-	setUserAgent(req)
+func newTraktSource(cfg *domain.ListConfig, client *http.Client) Source {
+	return &traktSource{listSource{cfg: cfg, client: client, field: FieldShows}}
+}
 
-	resp, err := s.httpClient.Do(req)
+func (s *traktSource) Fetch(ctx context.Context, logger *zerolog.Logger) (*Items, error) {
+	resp, err := s.get(ctx, logger, map[string]string{"trakt-api-version": "2"})
 	if err != nil {
-		l.Error().Err(err).Msgf("failed to fetch titles from URL: %s", cfg.URL)
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		l.Error().Msgf("failed to fetch titles from URL: %s", cfg.URL)
-		return fmt.Errorf("failed to fetch titles from URL: %s", cfg.URL)
-	}
-
 	contentType := resp.Header.Get("Content-Type")
 	if !strings.HasPrefix(contentType, "application/json") {
-		errMsg := fmt.Sprintf("invalid content type for URL: %s, content type should be application/json", cfg.URL)
-		return fmt.Errorf(errMsg)
+		return nil, fmt.Errorf("invalid content type for URL: %s, content type should be application/json", s.cfg.URL)
 	}
 
 	var data []struct {
@@ -72,10 +47,11 @@ func (s Service) trakt(ctx context.Context, cfg *domain.ListConfig, dryRun bool,
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		l.Error().Err(err).Msgf("failed to decode JSON data from URL: %s", cfg.URL)
-		return err
+		logger.Error().Err(err).Msgf("failed to decode JSON data from URL: %s", s.cfg.URL)
+		return nil, err
 	}
 
+	var titles []string
 	for _, item := range data {
 		titles = append(titles, item.Title)
 		if item.Movie.Title != "" {
@@ -86,38 +62,5 @@ func (s Service) trakt(ctx context.Context, cfg *domain.ListConfig, dryRun bool,
 		}
 	}
 
-	for _, filterID := range cfg.Filters {
-		l.Debug().Msgf("updating filter: %v", filterID)
-
-		filterTitles := []string{}
-		for _, title := range titles {
-			filterTitles = append(filterTitles, processTitle(title, cfg.MatchRelease)...)
-		}
-
-		joinedTitles := strings.Join(filterTitles, ",")
-
-		l.Trace().Msgf("%s", joinedTitles)
-
-		if len(joinedTitles) == 0 {
-			l.Debug().Msgf("no titles found for filter: %v", filterID)
-			return nil
-		}
-
-		f := autobrr.UpdateFilter{Shows: strings.Join(filterTitles, ",")}
-
-		if cfg.MatchRelease {
-			f = autobrr.UpdateFilter{MatchReleases: joinedTitles}
-		}
-
-		if !dryRun {
-			if err := brr.UpdateFilterByID(ctx, filterID, f); err != nil {
-				l.Error().Err(err).Msgf("error updating filter: %v", filterID)
-				return errors.Wrapf(err, "error updating filter: %v", filterID)
-			}
-		}
-
-		l.Debug().Msgf("successfully updated filter: %v", filterID)
-	}
-
-	return nil
+	return &Items{Titles: titles}, nil
 }
@@ -8,10 +8,12 @@ import (
 	"testing"
 
 	"github.com/autobrr/omegabrr/internal/domain"
-	"github.com/autobrr/omegabrr/pkg/autobrr"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
 )
 
-// Unit test for the `trakt` function with mocked dependencies.
+// Unit test for the `trakt` source with mocked dependencies.
 func TestTraktList(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Return sample JSON response for testing
@@ -22,16 +24,17 @@ func TestTraktList(t *testing.T) {
 
 	cfg := &domain.ListConfig{
 		Name: "test",
+		Type: domain.ListTypeTrakt,
 		//URL:  "https://api.autobrr.com/lists/trakt/anticipated-tv",
 		URL: ts.URL,
 	}
 
-	brr := &autobrr.Client{}
-
-	service := NewService(nil)
+	src := newListSource(cfg, ts.Client())
 
-	err := service.trakt(context.Background(), cfg, false, brr)
+	items, err := src.Fetch(context.Background(), &log.Logger)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
+
+	assert.Equal(t, []string{"Movie 1", "Movie 1 Title", "Movie 2", "Show 1 Title"}, items.Titles)
 }
@@ -0,0 +1,165 @@
+package processor
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"golift.io/starr/radarr"
+)
+
+func init() {
+	RegisterArrSource(domain.ArrTypeWhisparr, newWhisparrSource)
+}
+
+// whisparrSystemStatus is the part of /api/v3/system/status used to tell Whisparr
+// versions apart. Both v2 and v3 serve it.
+type whisparrSystemStatus struct {
+	Version string `json:"version"`
+}
+
+// whisparrItem is a movie or scene of Whisparr v3, from /api/v3/movie.
+type whisparrItem struct {
+	Title     string `json:"title"`
+	Monitored bool   `json:"monitored"`
+	Tags      []int  `json:"tags"`
+}
+
+// whisparrStudio is a site of Whisparr v3, from /api/v3/studio.
+type whisparrStudio struct {
+	Title     string `json:"title"`
+	Monitored bool   `json:"monitored"`
+	Tags      []int  `json:"tags"`
+}
+
+// whisparrPerformer is a performer of Whisparr v3, from /api/v3/performer.
+type whisparrPerformer struct {
+	FullName  string `json:"fullName"`
+	Monitored bool   `json:"monitored"`
+	Tags      []int  `json:"tags"`
+}
+
+// whisparrSource fetches from Whisparr v2, which is built on Sonarr and shares its
+// API, and from the movie and scene based Whisparr v3.
+type whisparrSource struct {
+	arrSource
+}
+
+func newWhisparrSource(cfg *domain.ArrConfig) Source {
+	return &whisparrSource{arrSource{cfg: cfg, field: FieldShows}}
+}
+
+func (s *whisparrSource) Fetch(ctx context.Context, logger *zerolog.Logger) (*Items, error) {
+	// starr has no package for Whisparr v3, it is built on Radarr and its client
+	// works for the endpoints they share, and for GetInto
+	r := radarr.New(newStarrConfig(s.cfg))
+
+	version := s.cfg.WhisparrVersion
+	if version == 0 {
+		detected, err := detectWhisparrVersion(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		version = detected
+
+		logger.Debug().Msgf("detected whisparr v%d", version)
+	}
+
+	if version < 3 {
+		if s.cfg.Emits(domain.ArrEmitSites) || s.cfg.Emits(domain.ArrEmitPerformers) {
+			return nil, errors.New("sites and performers need whisparr v3")
+		}
+
+		return (&sonarrSource{s.arrSource}).Fetch(ctx, logger)
+	}
+
+	return s.fetchV3(ctx, r, logger)
+}
+
+// detectWhisparrVersion returns the major version of Whisparr from its system status.
+func detectWhisparrVersion(ctx context.Context, r *radarr.Radarr) (int, error) {
+	var status whisparrSystemStatus
+	if err := r.GetInto(ctx, "v3/system/status", nil, &status); err != nil {
+		return 0, errors.Wrap(err, "could not get whisparr version, set whisparrVersion to skip detecting it")
+	}
+
+	major, _, _ := strings.Cut(status.Version, ".")
+	version, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, errors.Errorf("could not detect whisparr version from %q, set whisparrVersion to skip detecting it", status.Version)
+	}
+
+	return version, nil
+}
+
+func (s *whisparrSource) fetchV3(ctx context.Context, r *radarr.Radarr, logger *zerolog.Logger) (*Items, error) {
+	tags, err := s.fetchTags(ctx, r, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var titles []string
+
+	if s.cfg.Emits(domain.ArrEmitTitles) {
+		var items []*whisparrItem
+		if err := r.GetInto(ctx, "v3/movie", nil, &items); err != nil {
+			return nil, errors.Wrap(err, "could not get movies and scenes")
+		}
+
+		var processedItems int
+		for _, item := range items {
+			if !s.selects(tags, item.Monitored, item.Tags) || item.Title == "" {
+				continue
+			}
+
+			processedItems++
+			titles = append(titles, item.Title)
+		}
+
+		logger.Debug().Msgf("from a total of %d movies and scenes we found %d titles", len(items), processedItems)
+	}
+
+	if s.cfg.Emits(domain.ArrEmitSites) {
+		var studios []*whisparrStudio
+		if err := r.GetInto(ctx, "v3/studio", nil, &studios); err != nil {
+			return nil, errors.Wrap(err, "could not get sites")
+		}
+
+		var processedStudios int
+		for _, studio := range studios {
+			if !s.selects(tags, studio.Monitored, studio.Tags) || studio.Title == "" {
+				continue
+			}
+
+			processedStudios++
+			titles = append(titles, studio.Title)
+		}
+
+		logger.Debug().Msgf("from a total of %d sites we found %d sites", len(studios), processedStudios)
+	}
+
+	if s.cfg.Emits(domain.ArrEmitPerformers) {
+		var performers []*whisparrPerformer
+		if err := r.GetInto(ctx, "v3/performer", nil, &performers); err != nil {
+			return nil, errors.Wrap(err, "could not get performers")
+		}
+
+		var processedPerformers int
+		for _, performer := range performers {
+			if !s.selects(tags, performer.Monitored, performer.Tags) || performer.FullName == "" {
+				continue
+			}
+
+			processedPerformers++
+			titles = append(titles, performer.FullName)
+		}
+
+		logger.Debug().Msgf("from a total of %d performers we found %d performers", len(performers), processedPerformers)
+	}
+
+	return &Items{Titles: titles}, nil
+}
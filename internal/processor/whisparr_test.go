@@ -0,0 +1,79 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhisparr_Fetch_v2(t *testing.T) {
+	ts := newArrServer(t, map[string]string{
+		"/api/v3/system/status": `{"appName": "Whisparr", "version": "2.0.0.548"}`,
+		"/api/v3/series":        `[{"title": "Site", "monitored": true}, {"title": "Other Site", "monitored": false}]`,
+	})
+
+	cfg := &domain.ArrConfig{Name: "whisparr", Type: domain.ArrTypeWhisparr, Host: ts.URL, Apikey: "key"}
+
+	items, err := newArrSource(cfg).Fetch(context.Background(), &log.Logger)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Site"}, items.Titles)
+
+	t.Run("sites need v3", func(t *testing.T) {
+		cfg := *cfg
+		cfg.Emit = []domain.ArrEmit{domain.ArrEmitSites}
+
+		_, err := newArrSource(&cfg).Fetch(context.Background(), &log.Logger)
+		assert.EqualError(t, err, "sites and performers need whisparr v3")
+	})
+}
+
+func TestWhisparr_Fetch_v3(t *testing.T) {
+	ts := newArrServer(t, map[string]string{
+		"/api/v3/system/status": `{"appName": "Whisparr", "version": "3.0.0.1020"}`,
+		"/api/v3/tag":           `[{"id": 1, "label": "skip"}]`,
+		"/api/v3/movie": `[
+			{"title": "A Movie", "itemType": "movie", "monitored": true},
+			{"title": "A Scene", "itemType": "scene", "monitored": true},
+			{"title": "Skipped Scene", "itemType": "scene", "monitored": true, "tags": [1]},
+			{"title": "Unmonitored Scene", "itemType": "scene", "monitored": false}
+		]`,
+		"/api/v3/studio":    `[{"title": "Some Site", "monitored": true}, {"title": "Other Site", "monitored": false}]`,
+		"/api/v3/performer": `[{"fullName": "Jane Doe", "monitored": true}, {"fullName": "John Doe", "monitored": true, "tags": [1]}]`,
+	})
+
+	tests := []struct {
+		name string
+		cfg  domain.ArrConfig
+		want []string
+	}{
+		{
+			name: "titles",
+			want: []string{"A Movie", "A Scene", "Skipped Scene"},
+		},
+		{
+			name: "sites and performers",
+			cfg:  domain.ArrConfig{Emit: []domain.ArrEmit{domain.ArrEmitSites, domain.ArrEmitPerformers}},
+			want: []string{"Some Site", "Jane Doe", "John Doe"},
+		},
+		{
+			name: "tags",
+			cfg:  domain.ArrConfig{Emit: []domain.ArrEmit{domain.ArrEmitTitles, domain.ArrEmitPerformers}, TagsExclude: []string{"skip"}},
+			want: []string{"A Movie", "A Scene", "Jane Doe"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Name, cfg.Type, cfg.Host, cfg.Apikey = "whisparr", domain.ArrTypeWhisparr, ts.URL, "key"
+
+			items, err := newArrSource(&cfg).Fetch(context.Background(), &log.Logger)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, items.Titles)
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package scheduler
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+	"github.com/autobrr/omegabrr/internal/processor"
+
+	"github.com/rs/zerolog/log"
+)
+
+// maxDebounceFactor caps how long a steady stream of triggers can hold back a
+// run, as a multiple of the debounce window.
+const maxDebounceFactor = 5
+
+// Coordinator makes sure only one run happens at a time. Triggers that arrive
+// while a run is in progress, or shortly after each other, are merged into a
+// single run that starts once the current one is done. Dry runs and real runs
+// are never merged, so each waits in a run of its own.
+type Coordinator struct {
+	processorService *processor.Service
+	debounce         time.Duration
+
+	mu      sync.Mutex
+	running *Ticket
+	pending []*Ticket
+}
+
+// Ticket is a run requested from the coordinator. Triggers merged into the same
+// run share a ticket.
+type Ticket struct {
+	id    string
+	opts  processor.RunOptions
+	first time.Time
+	ready bool
+	timer *time.Timer
+
+	done   chan struct{}
+	report *domain.RunReport
+}
+
+// ID is the ID the run gets when it starts.
+func (t *Ticket) ID() string {
+	return t.id
+}
+
+// Wait blocks until the run finished and returns its report.
+func (t *Ticket) Wait(ctx context.Context) (*domain.RunReport, error) {
+	select {
+	case <-t.done:
+		return t.report, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// CoordinatorStatus tells which run is in progress and which is waiting next.
+type CoordinatorStatus struct {
+	Running string `json:"running,omitempty"`
+	Pending string `json:"pending,omitempty"`
+}
+
+func NewCoordinator(processorSvc *processor.Service, debounce time.Duration) *Coordinator {
+	return &Coordinator{
+		processorService: processorSvc,
+		debounce:         debounce,
+	}
+}
+
+// Trigger requests a run once no other triggers arrived for the debounce window.
+func (c *Coordinator) Trigger(opts processor.RunOptions) *Ticket {
+	return c.submit(opts, -1)
+}
+
+// RunNow requests a run without waiting for other triggers. Triggers held back
+// by the debounce window join it and start with it. It still waits for a run in
+// progress to finish.
+func (c *Coordinator) RunNow(opts processor.RunOptions) *Ticket {
+	return c.submit(opts, 0)
+}
+
+// SetDebounce changes the debounce window for triggers from now on.
+func (c *Coordinator) SetDebounce(debounce time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.debounce = debounce
+}
+
+// Status returns the IDs of the run in progress and the run waiting to start.
+func (c *Coordinator) Status() CoordinatorStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var status CoordinatorStatus
+	if c.running != nil {
+		status.Running = c.running.id
+	}
+	if len(c.pending) > 0 {
+		status.Pending = c.pending[0].id
+	}
+
+	return status
+}
+
+// submit adds a trigger to the pending run. A negative debounce uses the debounce window.
+func (c *Coordinator) submit(opts processor.RunOptions, debounce time.Duration) *Ticket {
+	c.mu.Lock()
+
+	if debounce < 0 {
+		debounce = c.debounce
+	}
+
+	// a dry run joining a real run would write to autobrr, and the other way around
+	// a real trigger would not, so only runs of the same kind are merged
+	var t *Ticket
+	for _, p := range c.pending {
+		if p.opts.DryRun == opts.DryRun {
+			t = p
+			break
+		}
+	}
+
+	if t == nil {
+		t = &Ticket{
+			opts:  opts,
+			first: time.Now(),
+			done:  make(chan struct{}),
+		}
+		t.id = c.processorService.Queue(opts)
+		t.opts.ID = t.id
+		c.pending = append(c.pending, t)
+	} else {
+		t.opts = mergeOptions(t.opts, opts)
+		log.Debug().Str("run", t.id).Msgf("merged %s trigger into pending run", opts.Trigger)
+	}
+
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+
+	// a burst of triggers can't hold back the run forever
+	if limit := time.Until(t.first.Add(maxDebounceFactor * c.debounce)); debounce > limit {
+		debounce = limit
+	}
+
+	if debounce > 0 {
+		t.timer = time.AfterFunc(debounce, func() {
+			c.mu.Lock()
+			t.ready = true
+			c.mu.Unlock()
+
+			c.startNext()
+		})
+	} else {
+		t.ready = true
+	}
+
+	c.mu.Unlock()
+
+	c.startNext()
+
+	return t
+}
+
+// startNext starts the first pending run that is ready, if no other run is in progress.
+func (c *Coordinator) startNext() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.running != nil {
+		return
+	}
+
+	i := slices.IndexFunc(c.pending, func(t *Ticket) bool { return t.ready })
+	if i < 0 {
+		return
+	}
+
+	t := c.pending[i]
+	c.pending = slices.Delete(c.pending, i, i+1)
+	c.running = t
+
+	go func() {
+		t.report = c.processorService.Run(context.Background(), t.opts)
+		close(t.done)
+
+		c.mu.Lock()
+		c.running = nil
+		c.mu.Unlock()
+
+		c.startNext()
+	}()
+}
+
+// mergeOptions widens a pending run so it covers another trigger as well. Both
+// must be dry runs or both real runs.
+func mergeOptions(a, b processor.RunOptions) processor.RunOptions {
+	switch {
+	case a.Kind == b.Kind && len(a.Sources) > 0 && len(b.Sources) > 0:
+		a.Sources = mergeNames(a.Sources, b.Sources)
+	case a.Kind == b.Kind && len(a.Sources) == 0 && len(b.Sources) == 0:
+	default:
+		// the triggers select different things, so process everything
+		a.Kind = ""
+		a.Sources = nil
+	}
+
+	// the run keeps the trigger it was queued by, the others are recorded with it
+	for _, trigger := range append([]domain.RunTrigger{b.Trigger}, b.MergedTriggers...) {
+		if trigger != a.Trigger && !slices.Contains(a.MergedTriggers, trigger) {
+			a.MergedTriggers = append(a.MergedTriggers, trigger)
+		}
+	}
+
+	return a
+}
+
+func mergeNames(a, b []string) []string {
+	seen := make(map[string]struct{}, len(a)+len(b))
+	merged := make([]string, 0, len(a)+len(b))
+
+	for _, name := range append(append([]string{}, a...), b...) {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		merged = append(merged, name)
+	}
+
+	return merged
+}
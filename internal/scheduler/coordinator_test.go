@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+	"github.com/autobrr/omegabrr/internal/processor"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newProcessorConfig() *domain.Config {
+	cfg := &domain.Config{}
+	cfg.Clients.Autobrr = &domain.AutobrrConfig{Host: "http://localhost:7474", Apikey: "key"}
+
+	return cfg
+}
+
+func newProcessor() *processor.Service {
+	return processor.NewService(newProcessorConfig())
+}
+
+func TestCoordinator_Trigger_debounces(t *testing.T) {
+	c := NewCoordinator(newProcessor(), 50*time.Millisecond)
+
+	first := c.Trigger(processor.RunOptions{Trigger: domain.RunTriggerWebhook, Kind: processor.SourceKindArr})
+	second := c.Trigger(processor.RunOptions{Trigger: domain.RunTriggerWebhook, Kind: processor.SourceKindList})
+
+	assert.Same(t, first, second)
+	assert.Equal(t, first.ID(), c.Status().Pending)
+	assert.Equal(t, processor.SourceKind(""), first.opts.Kind)
+
+	run, err := first.Wait(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID(), run.ID)
+}
+
+func TestCoordinator_RunNow_waitsForRunning(t *testing.T) {
+	c := NewCoordinator(newProcessor(), time.Minute)
+
+	c.mu.Lock()
+	c.running = &Ticket{id: "busy", done: make(chan struct{})}
+	c.mu.Unlock()
+
+	ticket := c.RunNow(processor.RunOptions{Trigger: domain.RunTriggerSchedule})
+	assert.Equal(t, CoordinatorStatus{Running: "busy", Pending: ticket.ID()}, c.Status())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := ticket.Wait(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestCoordinator_RunNow_flushesPending(t *testing.T) {
+	c := NewCoordinator(newProcessor(), time.Minute)
+
+	held := c.Trigger(processor.RunOptions{Trigger: domain.RunTriggerWebhook, Kind: processor.SourceKindArr})
+	now := c.RunNow(processor.RunOptions{Trigger: domain.RunTriggerWebhook, Kind: processor.SourceKindArr})
+	assert.Same(t, held, now)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	run, err := now.Wait(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, held.ID(), run.ID)
+}
+
+func TestCoordinator_Trigger_keepsDryRunsApart(t *testing.T) {
+	c := NewCoordinator(newProcessor(), time.Minute)
+
+	dry := c.Trigger(processor.RunOptions{Trigger: domain.RunTriggerWebhook, DryRun: true})
+	write := c.Trigger(processor.RunOptions{Trigger: domain.RunTriggerWebhook})
+	again := c.Trigger(processor.RunOptions{Trigger: domain.RunTriggerSchedule, DryRun: true})
+
+	assert.NotSame(t, dry, write)
+	assert.Same(t, dry, again)
+	assert.True(t, dry.opts.DryRun)
+	assert.False(t, write.opts.DryRun)
+	assert.Equal(t, dry.ID(), c.Status().Pending)
+}
+
+func Test_mergeOptions(t *testing.T) {
+	merged := mergeOptions(
+		processor.RunOptions{Trigger: domain.RunTriggerWebhook, Kind: processor.SourceKindArr},
+		processor.RunOptions{Trigger: domain.RunTriggerSchedule, Kind: processor.SourceKindArr},
+	)
+	merged = mergeOptions(merged, processor.RunOptions{Trigger: domain.RunTriggerSchedule, Kind: processor.SourceKindArr})
+	merged = mergeOptions(merged, processor.RunOptions{Trigger: domain.RunTriggerWebhook, Kind: processor.SourceKindArr})
+
+	assert.Equal(t, domain.RunTriggerWebhook, merged.Trigger)
+	assert.Equal(t, []domain.RunTrigger{domain.RunTriggerSchedule}, merged.MergedTriggers)
+	assert.Equal(t, processor.SourceKindArr, merged.Kind)
+}
+
+func Test_mergeOptions_sources(t *testing.T) {
+	merged := mergeOptions(
+		processor.RunOptions{Trigger: domain.RunTriggerSchedule, Sources: []string{"sonarr", "radarr"}},
+		processor.RunOptions{Trigger: domain.RunTriggerSchedule, Sources: []string{"radarr", "trakt"}},
+	)
+	assert.Equal(t, []string{"sonarr", "radarr", "trakt"}, merged.Sources)
+
+	merged = mergeOptions(
+		processor.RunOptions{Trigger: domain.RunTriggerSchedule, Sources: []string{"sonarr"}},
+		processor.RunOptions{Trigger: domain.RunTriggerWebhook, Kind: processor.SourceKindList},
+	)
+	assert.Empty(t, merged.Sources)
+	assert.Equal(t, processor.SourceKind(""), merged.Kind)
+}
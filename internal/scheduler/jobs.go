@@ -2,33 +2,50 @@ package scheduler
 
 import (
 	"context"
+	"math/rand"
+	"time"
 
+	"github.com/autobrr/omegabrr/internal/domain"
 	"github.com/autobrr/omegabrr/internal/processor"
 
 	"github.com/rs/zerolog"
 )
 
 type RunProcessorJob struct {
-	Name             string
-	Log              zerolog.Logger
-	ProcessorService *processor.Service
+	Name        string
+	Log         zerolog.Logger
+	Coordinator *Coordinator
+	// Sources limits the job to the arrs, lists and sets with these names. All of them are processed when empty.
+	Sources []string
+	// Jitter delays every run by a random duration up to this long.
+	Jitter time.Duration
+	// Paused skips the run while it returns true.
+	Paused func() bool
 }
 
 func (j *RunProcessorJob) Run() {
 	ctx := context.Background()
 
-	arrsErrors := j.ProcessorService.ProcessArrs(ctx, false)
-	if len(arrsErrors) > 0 {
-		j.Log.Error().Msg("Errors encountered during processing Arrs:")
-		for _, errMsg := range arrsErrors {
-			j.Log.Error().Msg(errMsg)
-		}
+	if j.Jitter > 0 {
+		delay := time.Duration(rand.Int63n(int64(j.Jitter)))
+		j.Log.Debug().Msgf("waiting %s before running...", delay.Round(time.Second))
+		time.Sleep(delay)
+	}
+
+	if j.Paused != nil && j.Paused() {
+		j.Log.Info().Msg("scheduler is paused, skipping run")
+		return
+	}
+
+	run, err := j.Coordinator.RunNow(processor.RunOptions{Trigger: domain.RunTriggerSchedule, Sources: j.Sources}).Wait(ctx)
+	if err != nil {
+		j.Log.Error().Err(err).Msg("error waiting for run")
+		return
 	}
 
-	listsErrors := j.ProcessorService.ProcessLists(ctx, false)
-	if len(listsErrors) > 0 {
-		j.Log.Error().Msg("Errors encountered during processing Lists:")
-		for _, errMsg := range listsErrors {
+	if errs := run.Errors(); len(errs) > 0 {
+		j.Log.Error().Msgf("Errors encountered during run %s:", run.ID)
+		for _, errMsg := range errs {
 			j.Log.Error().Msg(errMsg)
 		}
 	}
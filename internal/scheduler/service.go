@@ -1,6 +1,11 @@
 package scheduler
 
 import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/autobrr/omegabrr/internal/domain"
@@ -11,30 +16,81 @@ import (
 )
 
 type Service struct {
-	cfg              *domain.Config
-	processorService *processor.Service
+	cfg         *domain.Config
+	coordinator *Coordinator
+	location    *time.Location
+	paused      atomic.Bool
 
 	cron *cron.Cron
-	jobs map[string]cron.EntryID
+
+	mu        sync.RWMutex
+	jobs      map[string]cron.EntryID
+	schedules map[string]string
+}
+
+// JobStatus describes a scheduled job and when it runs.
+type JobStatus struct {
+	Name     string     `json:"name"`
+	Schedule string     `json:"schedule"`
+	Next     time.Time  `json:"next"`
+	Prev     *time.Time `json:"prev,omitempty"`
+}
+
+// Status is the state of the scheduler and its jobs.
+type Status struct {
+	Paused   bool        `json:"paused"`
+	Timezone string      `json:"timezone"`
+	Jobs     []JobStatus `json:"jobs"`
 }
 
-func NewService(cfg *domain.Config, processorSvc *processor.Service) *Service {
+func NewService(cfg *domain.Config, coordinator *Coordinator) *Service {
+	location := loadLocation(cfg.Scheduler.Timezone)
+
 	return &Service{
-		cfg:              cfg,
-		processorService: processorSvc,
-		cron: cron.New(cron.WithChain(
+		cfg:         cfg,
+		coordinator: coordinator,
+		location:    location,
+		cron:        newCron(location),
+		jobs:        map[string]cron.EntryID{},
+		schedules:   map[string]string{},
+	}
+}
+
+func newCron(location *time.Location) *cron.Cron {
+	return cron.New(
+		cron.WithLocation(location),
+		cron.WithChain(
 			cron.Recover(cron.DefaultLogger),
-		)),
-		jobs: map[string]cron.EntryID{},
+		),
+	)
+}
+
+func loadLocation(tz string) *time.Location {
+	if tz == "" {
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		log.Error().Err(err).Msgf("invalid scheduler timezone %q, using local time", tz)
+		return time.Local
 	}
+
+	return loc
 }
 
 func (s *Service) Start() {
 	log.Info().Msg("starting scheduler")
 
+	s.initJobs()
+
+	s.mu.RLock()
 	s.cron.Start()
+	s.mu.RUnlock()
 
-	s.initJobs()
+	if s.config().Scheduler.RunOnStart {
+		go s.runOnStart()
+	}
 
 	return
 }
@@ -42,43 +98,197 @@ func (s *Service) Start() {
 func (s *Service) Stop() {
 	log.Info().Msg("stopping scheduler")
 
+	s.mu.RLock()
 	s.cron.Stop()
+	s.mu.RUnlock()
 
 	return
 }
 
+// Reload replaces the jobs with those of a reloaded config. Runs in progress
+// are not interrupted, and a paused scheduler stays paused.
+func (s *Service) Reload(cfg *domain.Config) {
+	location := loadLocation(cfg.Scheduler.Timezone)
+
+	s.mu.Lock()
+	previous := s.cron
+	s.cfg = cfg
+	s.location = location
+	s.cron = newCron(location)
+	s.jobs = map[string]cron.EntryID{}
+	s.schedules = map[string]string{}
+	s.mu.Unlock()
+
+	previous.Stop()
+
+	s.initJobs()
+
+	s.mu.RLock()
+	s.cron.Start()
+	s.mu.RUnlock()
+
+	log.Info().Msg("scheduler reloaded")
+}
+
+func (s *Service) config() *domain.Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.cfg
+}
+
+// Pause skips scheduled runs until Resume is called. Jobs keep their schedule,
+// so their next run time is still reported.
+func (s *Service) Pause() {
+	if !s.paused.Swap(true) {
+		log.Info().Msg("scheduler paused")
+	}
+}
+
+func (s *Service) Resume() {
+	if s.paused.Swap(false) {
+		log.Info().Msg("scheduler resumed")
+	}
+}
+
+func (s *Service) Paused() bool {
+	return s.paused.Load()
+}
+
+// Status returns the jobs with their next and previous run time, ordered by name.
+func (s *Service) Status() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := Status{
+		Paused:   s.Paused(),
+		Timezone: s.location.String(),
+		Jobs:     make([]JobStatus, 0, len(s.jobs)),
+	}
+
+	for name, id := range s.jobs {
+		entry := s.cron.Entry(id)
+
+		job := JobStatus{
+			Name:     name,
+			Schedule: s.schedules[name],
+			Next:     entry.Next,
+		}
+		if !entry.Prev.IsZero() {
+			job.Prev = &entry.Prev
+		}
+
+		status.Jobs = append(status.Jobs, job)
+	}
+
+	sort.Slice(status.Jobs, func(i, j int) bool { return status.Jobs[i].Name < status.Jobs[j].Name })
+
+	return status
+}
+
 func (s *Service) AddJob(job cron.Job, interval string, identifier string) (int, error) {
 	if interval == "" {
 		interval = "0 */6 * * *"
 	}
 
-	if id, err := s.cron.AddJob(interval, cron.NewChain(
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := s.cron.AddJob(interval, cron.NewChain(
 		cron.SkipIfStillRunning(cron.DefaultLogger)).Then(job),
-	); err != nil {
+	)
+	if err != nil {
 		return 0, err
-	} else {
-		s.jobs[identifier] = id
 	}
 
+	s.jobs[identifier] = id
+	s.schedules[identifier] = interval
+
 	log.Info().Msgf("job successfully added: %v", identifier)
 
-	return 0, nil
+	return int(id), nil
 }
 
 func (s *Service) initJobs() {
 	log.Info().Msg("init jobs")
 
-	time.Sleep(2 * time.Second)
+	cfg := s.config()
+	groups := cfg.SourceSchedules()
 
-	p := &RunProcessorJob{
-		Name:             "process-filters",
-		Log:              log.With().Str("job", "process-filters").Logger(),
-		ProcessorService: s.processorService,
+	if len(groups) == 0 {
+		s.addProcessorJob("process-filters", cfg.Schedule, nil)
+		return
 	}
 
-	if _, err := s.AddJob(p, s.cfg.Schedule, "process-filters"); err != nil {
-		log.Error().Err(err).Msg("error adding job: process-filters")
+	// a single schedule for everything needs no source selection, but it is not
+	// the global schedule when every source overrides it
+	if len(groups) == 1 {
+		for schedule := range groups {
+			s.addProcessorJob(processorJobName(cfg.Schedule, schedule), schedule, nil)
+		}
+		return
+	}
+
+	schedules := make([]string, 0, len(groups))
+	for schedule := range groups {
+		schedules = append(schedules, schedule)
+	}
+	sort.Strings(schedules)
+
+	for _, schedule := range schedules {
+		s.addProcessorJob(processorJobName(cfg.Schedule, schedule), schedule, groups[schedule])
 	}
 
 	return
 }
+
+// processorJobName names the job of a schedule after it, unless it is the global schedule.
+func processorJobName(global, schedule string) string {
+	if schedule == global {
+		return "process-filters"
+	}
+
+	return fmt.Sprintf("process-filters (%s)", schedule)
+}
+
+func (s *Service) addProcessorJob(identifier, schedule string, sources []string) {
+	p := &RunProcessorJob{
+		Name:        identifier,
+		Log:         log.With().Str("job", identifier).Logger(),
+		Coordinator: s.coordinator,
+		Sources:     sources,
+		Jitter:      s.config().Scheduler.Jitter,
+		Paused:      s.Paused,
+	}
+
+	if _, err := s.AddJob(p, schedule, identifier); err != nil {
+		log.Error().Err(err).Msgf("error adding job: %s", identifier)
+	}
+}
+
+// runOnStart processes everything once, after the configured delay.
+func (s *Service) runOnStart() {
+	if delay := s.config().Scheduler.StartDelay; delay > 0 {
+		log.Debug().Msgf("waiting %s before running...", delay)
+		time.Sleep(delay)
+	}
+
+	ctx := context.Background()
+
+	run, err := s.coordinator.RunNow(processor.RunOptions{Trigger: domain.RunTriggerStartup}).Wait(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("error waiting for run")
+		return
+	}
+
+	// Print the summary of potential errors
+	if processingErrors := run.Errors(); len(processingErrors) == 0 {
+		log.Info().Msg("Run complete.")
+	} else {
+		log.Warn().Msg("Run complete, with errors.")
+		log.Warn().Msg("Errors encountered during processing:")
+		for _, errMsg := range processingErrors {
+			log.Warn().Msg(errMsg)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/autobrr/omegabrr/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_Status(t *testing.T) {
+	cfg := newProcessorConfig()
+	cfg.Schedule = "0 */6 * * *"
+	cfg.Scheduler.Timezone = "Europe/Berlin"
+
+	s := NewService(cfg, NewCoordinator(nil, time.Minute))
+	s.initJobs()
+	s.cron.Start()
+	defer s.cron.Stop()
+
+	status := s.Status()
+	assert.False(t, status.Paused)
+	assert.Equal(t, "Europe/Berlin", status.Timezone)
+	assert.Len(t, status.Jobs, 1)
+	assert.Equal(t, "process-filters", status.Jobs[0].Name)
+	assert.Equal(t, "0 */6 * * *", status.Jobs[0].Schedule)
+	assert.False(t, status.Jobs[0].Next.IsZero())
+	assert.Nil(t, status.Jobs[0].Prev)
+
+	s.Pause()
+	assert.True(t, s.Status().Paused)
+
+	s.Resume()
+	assert.False(t, s.Status().Paused)
+}
+
+func TestService_initJobs_singleOverride(t *testing.T) {
+	cfg := newProcessorConfig()
+	cfg.Schedule = "0 */6 * * *"
+	cfg.Clients.Arr = []*domain.ArrConfig{{Name: "sonarr", Type: domain.ArrTypeSonarr, Schedule: "*/15 * * * *"}}
+
+	s := NewService(cfg, NewCoordinator(nil, time.Minute))
+	s.initJobs()
+
+	status := s.Status()
+	assert.Len(t, status.Jobs, 1)
+	assert.Equal(t, "process-filters (*/15 * * * *)", status.Jobs[0].Name)
+	assert.Equal(t, "*/15 * * * *", status.Jobs[0].Schedule)
+}
+
+func TestRunProcessorJob_skipsWhenPaused(t *testing.T) {
+	c := NewCoordinator(nil, time.Minute)
+
+	j := &RunProcessorJob{Name: "process-filters", Coordinator: c, Paused: func() bool { return true }}
+	j.Run()
+
+	assert.Equal(t, CoordinatorStatus{}, c.Status())
+}
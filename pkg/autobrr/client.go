@@ -106,6 +106,35 @@ func (c *Client) GetFilters(ctx context.Context) ([]Filter, error) {
 	return filters, nil
 }
 
+func (c *Client) GetFilterByID(ctx context.Context, filterID int) (*Filter, error) {
+	reqUrl, err := url.JoinPath(c.Host, "/api/filters/", strconv.Itoa(filterID))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodGet, reqUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status: %d", res.StatusCode)
+	}
+
+	var filter Filter
+	if err := json.NewDecoder(res.Body).Decode(&filter); err != nil {
+		return nil, err
+	}
+
+	return &filter, nil
+}
+
 func (c *Client) UpdateFilterByID(ctx context.Context, filterID int, filter UpdateFilter) error {
 	id := strconv.Itoa(filterID)
 
@@ -164,10 +193,14 @@ func (c *Client) buildUserAgent(req *http.Request) {
 }
 
 type Filter struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	UpdatedAt time.Time `json:"updated_at"`
-	CreatedAt time.Time `json:"created_at"`
+	ID            json.Number `json:"id"`
+	Name          string      `json:"name"`
+	Shows         string      `json:"shows,omitempty"`
+	Albums        string      `json:"albums,omitempty"`
+	Artists       string      `json:"artists,omitempty"`
+	MatchReleases string      `json:"match_releases,omitempty"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+	CreatedAt     time.Time   `json:"created_at"`
 }
 
 type UpdateFilter struct {